@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/putrasattvika/andotp-cli/pkg/clipboard"
+)
+
+// newClipboardHelperCmd creates the hidden command run by the detached process
+// clearing the clipboard
+func newClipboardHelperCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    clipboard.HelperCommand + " <delay>",
+		Short:  "Clear a copied token from the clipboard after a delay",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			delay, err := time.ParseDuration(args[0])
+			if err != nil {
+				log.Fatalf("error parsing/validating arguments: %v", err)
+			}
+
+			if err := clipboard.RunHelper(delay); err != nil {
+				log.Fatalf("error clearing clipboard: %v", err)
+			}
+		},
+	}
+}
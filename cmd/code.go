@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/awnumar/memguard"
+	"github.com/spf13/cobra"
+
+	"github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/code"
+	codeconfig "github.com/putrasattvika/andotp-cli/pkg/code/config"
+)
+
+type codeCmd struct {
+	config     *config.Config
+	codeConfig *config.CodeConfig
+}
+
+// newCodeCmd creates a new "code" command
+func newCodeCmd(rootConfig *config.Config) *cobra.Command {
+	codeCmdObj := &codeCmd{
+		config:     rootConfig,
+		codeConfig: &config.CodeConfig{},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "code <query>",
+		Short: "Print the current token of a single OTP key",
+		Long: "Print the current token of a single OTP key without starting an interactive session.\n\n" +
+			"The OTP key is looked up by its index (e.g. 3), issuer:label (e.g. GitHub:myuser), " +
+			"issuer, label or tag. With --fuzzy, the best fuzzy match of the issuer, label and tags " +
+			"is used if nothing matches exactly (e.g. \"ghb\" for GitHub). Exits with status 2 if no OTP key " +
+			"matches, 3 if more than one OTP key matches, 4 if the backup cannot be loaded and 1 on " +
+			"other errors.",
+		Args: cobra.ExactArgs(1),
+
+		Run: codeCmdObj.entrypoint,
+	}
+
+	cmd.Flags().BoolVarP(
+		&codeCmdObj.codeConfig.Clipboard,
+		"clipboard", "c",
+		false,
+		"Copy the token to the clipboard instead of printing it to stdout",
+	)
+
+	cmd.Flags().BoolVar(
+		&codeCmdObj.codeConfig.Fuzzy,
+		"fuzzy",
+		false,
+		"Use the best fuzzy match of the query if no OTP key matches it exactly. A typo may "+
+			"select another OTP key, and advance its HOTP counter",
+	)
+
+	return cmd
+}
+
+// Entrypoint for the "code" command
+func (c *codeCmd) entrypoint(cmd *cobra.Command, args []string) {
+	memguard.CatchInterrupt()
+	defer memguard.Purge()
+
+	c.codeConfig.Query = args[0]
+
+	codeConfig, err := codeconfig.ParseCmdConfig(c.config, c.codeConfig)
+	if err != nil {
+		log.Fatalf("error parsing/validating arguments: %v", err)
+	}
+
+	code_, err := code.NewCode(codeConfig)
+	if err != nil {
+		log.Fatalf("error creating code generator: %v", err)
+	}
+
+	if err := code_.Run(); err != nil {
+		log.Printf("error generating token: %v", err)
+		memguard.SafeExit(code.ExitCode(err))
+	}
+}
@@ -4,8 +4,149 @@ package config
 type Config struct {
 	// URI to an andOTP encrypted backup file
 	//
-	// Supports two sources:
+	// Supports three sources:
 	//   - local file (e.g. file:///home/myuser/otp_accounts.json.aes)
 	//   - KDE connect exposed device filesystem (e.g. kdeconnect://_/device-name-or-id/path/to/otp_accounts.json.aes)
+	//   - SFTP server (e.g. sftp://myuser@nas:22/~/backups/otp_accounts.json.aes)
 	BackupFileURI string
+
+	// Where to read the backup password from, one of "prompt" (default),
+	// "file:<path>", "env:<variable>", "fd:<number>" or "cmd:<shell command>"
+	PasswordSource string
+
+	// Format of the backup file, detected from its contents if empty
+	BackupFormat string
+
+	// Path to an armored or binary OpenPGP private key to decrypt OpenPGP
+	// backups with. If empty, they're decrypted by running the gpg binary,
+	// which talks to gpg-agent, so it must be installed.
+	PGPPrivateKey string
+
+	// When to clear a token copied to the clipboard: "period" (default, the
+	// remaining time of the key's period), "never" or a duration such as "45s"
+	ClipboardClearAfter string
+}
+
+// Configuration passed from the command line arguments of the interactive
+// shell, i.e. the root command
+type InteractiveConfig struct {
+	// Only show OTP keys having any of these tags
+	Tags []string
+}
+
+// Configuration passed from the command line arguments of the "code" command
+type CodeConfig struct {
+	// Index, issuer, label or tag of the OTP key to generate the token for
+	Query string
+
+	// Copy the token to the clipboard instead of printing it to stdout
+	Clipboard bool
+
+	// Fall back to the best fuzzy match if no OTP key matches exactly
+	Fuzzy bool
+}
+
+// Configuration passed from the command line arguments of the "keys" commands
+type KeysConfig struct {
+	// Path to write the modified backup to. The backup file is overwritten if
+	// empty.
+	Output string
+
+	// Index, issuer, label or tag of the OTP key to edit, remove or retag
+	Query string
+
+	// OTP key fields for "keys add" and "keys edit"
+	Issuer       string
+	Label        string
+	OTPType      string
+	Algorithm    string
+	Digits       int
+	Period       int
+	Counter      int64
+	Tags         []string
+	SecretSource string
+
+	// Tags to add to / remove from the OTP key for "keys tag"
+	AddTags    []string
+	RemoveTags []string
+
+	// Do not ask for confirmation before removing an OTP key
+	Yes bool
+
+	// Names of the flags explicitly set on the command line, used by
+	// "keys edit" to only change the given fields
+	ChangedFlags map[string]bool
+}
+
+// Configuration passed from the command line arguments of the "export" command
+type ExportConfig struct {
+	// Format of the exported OTP keys
+	Format string
+
+	// Path to write the exported OTP keys to, stdout if empty or "-"
+	Output string
+
+	// Show the exported URIs as terminal QR codes
+	QR bool
+
+	// Where to read the password of encrypted export formats from, same
+	// format as PasswordSource
+	VaultPasswordSource string
+
+	// Only export OTP keys having any of these tags
+	Tags []string
+
+	// Confirmation for exporting to unencrypted formats, i.e. all but
+	// aegis-encrypted
+	IUnderstandPlaintext bool
+}
+
+// Configuration passed from the command line arguments of the "import" command
+type ImportConfig struct {
+	// Format of the imported OTP keys
+	Format string
+
+	// Path to read the imported OTP keys from, stdin if empty or "-"
+	Input string
+
+	// Where to read the password of encrypted import formats from, same
+	// format as PasswordSource
+	VaultPasswordSource string
+
+	// Path to write the modified backup to. The backup file is overwritten if
+	// empty.
+	Output string
+}
+
+// Configuration passed from the command line arguments of the "qr" command
+type QRConfig struct {
+	// Index, issuer, label or tag of the OTP key to render
+	Query string
+
+	// Path to write the QR code as a PNG image to, instead of rendering it on
+	// the terminal
+	PNGOutput string
+
+	// Width & height of the PNG image in pixels
+	PNGSize int
+
+	// Render dark modules as filled blocks, for terminals with a light
+	// background
+	Invert bool
+
+	// Fall back to the best fuzzy match if no OTP key matches exactly
+	Fuzzy bool
+}
+
+// Configuration passed from the command line arguments of the "devices"
+// commands
+type DevicesConfig struct {
+	// Output format, "table" or "json"
+	Format string
+
+	// Name or ID of the KDE Connect device to browse for "devices ls"
+	Device string
+
+	// Directory of the device to list for "devices ls"
+	Path string
 }
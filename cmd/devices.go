@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/awnumar/memguard"
+	"github.com/spf13/cobra"
+
+	"github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/devices"
+	devicesconfig "github.com/putrasattvika/andotp-cli/pkg/devices/config"
+)
+
+type devicesCmd struct {
+	devicesConfig *config.DevicesConfig
+}
+
+// newDevicesCmd creates a new "devices" command group
+func newDevicesCmd() *cobra.Command {
+	devicesCmdObj := &devicesCmd{
+		devicesConfig: &config.DevicesConfig{},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "devices",
+		Short: "List KDE Connect devices and browse their filesystem",
+		Long: "List the KDE Connect devices known to the host with their name, ID, reachability, " +
+			"pairing state and SFTP endpoint. The SFTP endpoint is only known once the device's " +
+			"filesystem is mounted, e.g. by \"devices ls\".",
+		Args: cobra.NoArgs,
+
+		Run: devicesCmdObj.entrypoint((*devices.Devices).List),
+	}
+
+	cmd.PersistentFlags().StringVarP(
+		&devicesCmdObj.devicesConfig.Format,
+		"format", "f",
+		devicesconfig.FormatTable,
+		"Output format, "+devicesconfig.FormatTable+" or "+devicesconfig.FormatJSON,
+	)
+
+	// devices ls
+	lsCmd := &cobra.Command{
+		Use:   "ls <device> [path]",
+		Short: "List a directory of a KDE Connect device",
+		Long: "List a directory of a KDE Connect device, / if not given, mounting the device's " +
+			"filesystem if needed.\n\n" +
+			"The device is looked up by its name or ID. The latest andOTP backup of the directory " +
+			"is highlighted along with the --backup-file-uri to use it.",
+		Args: cobra.RangeArgs(1, 2),
+
+		Run: devicesCmdObj.entrypoint((*devices.Devices).Ls),
+	}
+
+	cmd.AddCommand(lsCmd)
+
+	return cmd
+}
+
+// entrypoint creates the entrypoint for a "devices" command which runs the
+// given Devices method
+func (c *devicesCmd) entrypoint(action func(*devices.Devices) error) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		memguard.CatchInterrupt()
+		defer memguard.Purge()
+
+		if len(args) > 0 {
+			c.devicesConfig.Device = args[0]
+		}
+
+		if len(args) > 1 {
+			c.devicesConfig.Path = args[1]
+		}
+
+		devicesConfig, err := devicesconfig.ParseCmdConfig(c.devicesConfig)
+		if err != nil {
+			log.Fatalf("error parsing/validating arguments: %v", err)
+		}
+
+		devices_, err := devices.NewDevices(devicesConfig)
+		if err != nil {
+			log.Fatalf("error creating device inspector: %v", err)
+		}
+
+		if err := action(devices_); err != nil {
+			log.Printf("error inspecting KDE Connect devices: %v", err)
+			memguard.SafeExit(1)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"log"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	"github.com/spf13/cobra"
+
+	"github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/exporter"
+	exporterconfig "github.com/putrasattvika/andotp-cli/pkg/exporter/config"
+)
+
+type exportCmd struct {
+	config       *config.Config
+	exportConfig *config.ExportConfig
+}
+
+// newExportCmd creates a new "export" command
+func newExportCmd(rootConfig *config.Config) *cobra.Command {
+	exportCmdObj := &exportCmd{
+		config:       rootConfig,
+		exportConfig: &config.ExportConfig{},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the OTP keys of an andOTP backup to another format",
+		Long: "Export the OTP keys of an andOTP backup to another format.\n\n" +
+			"Every export format except aegis-encrypted, including the default otpauth URIs, holds " +
+			"the secrets in plaintext and needs --i-understand-plaintext.\n\n" +
+			"In csv exports, issuers, labels and tags starting with =, +, - or @ are prefixed with ' " +
+			"so that spreadsheets don't evaluate them as formulas.",
+		Args: cobra.NoArgs,
+
+		Run: exportCmdObj.entrypoint,
+	}
+
+	cmd.Flags().StringVarP(
+		&exportCmdObj.exportConfig.Format,
+		"format", "f",
+		"otpauth",
+		"Export format, one of: "+strings.Join(exporter.AvailableFormats(), ", "),
+	)
+
+	cmd.Flags().StringVarP(
+		&exportCmdObj.exportConfig.Output,
+		"output", "o",
+		"-",
+		"Path to write the exported OTP keys to, or - for stdout",
+	)
+
+	cmd.Flags().StringVar(
+		&exportCmdObj.exportConfig.VaultPasswordSource,
+		"vault-password-source",
+		"prompt",
+		"Where to read the password of encrypted export formats (aegis-encrypted) from, "+
+			"same format as --password-source",
+	)
+
+	cmd.Flags().BoolVar(
+		&exportCmdObj.exportConfig.QR,
+		"qr",
+		false,
+		"Show the exported URIs as terminal QR codes, e.g. to scan the otpauth-migration "+
+			"batches with Google Authenticator",
+	)
+
+	cmd.Flags().StringSliceVarP(
+		&exportCmdObj.exportConfig.Tags,
+		"tag", "t",
+		nil,
+		"Only export OTP keys having any of these tags, can be repeated",
+	)
+
+	cmd.Flags().BoolVar(
+		&exportCmdObj.exportConfig.IUnderstandPlaintext,
+		"i-understand-plaintext",
+		false,
+		"Confirm exporting to a format that contains every secret unencrypted, i.e. any format but aegis-encrypted",
+	)
+
+	return cmd
+}
+
+// Entrypoint for the "export" command
+func (c *exportCmd) entrypoint(cmd *cobra.Command, args []string) {
+	memguard.CatchInterrupt()
+	defer memguard.Purge()
+
+	exportConfig, err := exporterconfig.ParseCmdConfig(c.config, c.exportConfig)
+	if err != nil {
+		log.Fatalf("error parsing/validating arguments: %v", err)
+	}
+
+	exporter_, err := exporter.NewExporter(exportConfig)
+	if err != nil {
+		log.Fatalf("error creating exporter: %v", err)
+	}
+
+	if err := exporter_.Export(); err != nil {
+		log.Printf("error exporting OTP keys: %v", err)
+		memguard.SafeExit(1)
+	}
+}
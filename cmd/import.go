@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"log"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	"github.com/spf13/cobra"
+
+	"github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/importer"
+	importerconfig "github.com/putrasattvika/andotp-cli/pkg/importer/config"
+)
+
+type importCmd struct {
+	config       *config.Config
+	importConfig *config.ImportConfig
+}
+
+// newImportCmd creates a new "import" command
+func newImportCmd(rootConfig *config.Config) *cobra.Command {
+	importCmdObj := &importCmd{
+		config:       rootConfig,
+		importConfig: &config.ImportConfig{},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "import [input]",
+		Short: "Import OTP keys from another format into an andOTP backup",
+		Long: "Import OTP keys from another format into an andOTP backup.\n\n" +
+			"OTP keys are read from the input file, or stdin if not given. OTP keys whose issuer " +
+			"and label already exist in the backup are skipped. The modified backup is encrypted " +
+			"with the same password it was decrypted with, then written back to the backup file, " +
+			"or to --output if given.",
+		Args: cobra.MaximumNArgs(1),
+
+		Run: importCmdObj.entrypoint,
+	}
+
+	cmd.Flags().StringVarP(
+		&importCmdObj.importConfig.Format,
+		"format", "f",
+		importer.FormatAuto,
+		"Import format, one of: "+strings.Join(importer.AvailableFormats(), ", ")+". "+
+			importer.FormatAuto+" detects the format from the input",
+	)
+
+	cmd.Flags().StringVar(
+		&importCmdObj.importConfig.VaultPasswordSource,
+		"vault-password-source",
+		"prompt",
+		"Where to read the password of encrypted imports from, "+
+			"same format as --password-source",
+	)
+
+	cmd.Flags().StringVarP(
+		&importCmdObj.importConfig.Output,
+		"output", "o",
+		"",
+		"Path to write the modified backup to instead of overwriting the backup file",
+	)
+
+	return cmd
+}
+
+// Entrypoint for the "import" command
+func (c *importCmd) entrypoint(cmd *cobra.Command, args []string) {
+	memguard.CatchInterrupt()
+	defer memguard.Purge()
+
+	if len(args) > 0 {
+		c.importConfig.Input = args[0]
+	}
+
+	importConfig, err := importerconfig.ParseCmdConfig(c.config, c.importConfig)
+	if err != nil {
+		log.Fatalf("error parsing/validating arguments: %v", err)
+	}
+
+	importer_, err := importer.NewImporter(importConfig)
+	if err != nil {
+		log.Fatalf("error creating importer: %v", err)
+	}
+
+	if err := importer_.Import(); err != nil {
+		log.Printf("error importing OTP keys: %v", err)
+		memguard.SafeExit(1)
+	}
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/awnumar/memguard"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/keys"
+	keysconfig "github.com/putrasattvika/andotp-cli/pkg/keys/config"
+)
+
+type keysCmd struct {
+	config     *config.Config
+	keysConfig *config.KeysConfig
+}
+
+// newKeysCmd creates a new "keys" command group
+func newKeysCmd(rootConfig *config.Config) *cobra.Command {
+	keysCmdObj := &keysCmd{
+		config:     rootConfig,
+		keysConfig: &config.KeysConfig{},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Add, edit, remove and retag OTP keys of an andOTP backup",
+		Long: "Add, edit, remove and retag OTP keys of an andOTP backup.\n\n" +
+			"The modified backup is encrypted with the same password it was decrypted with, " +
+			"then written back to the backup file, or to --output if given.",
+	}
+
+	cmd.PersistentFlags().StringVarP(
+		&keysCmdObj.keysConfig.Output,
+		"output", "o",
+		"",
+		"Path to write the modified backup to instead of overwriting the backup file",
+	)
+
+	// keys add
+	addCmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a new OTP key",
+		Args:  cobra.NoArgs,
+
+		Run: keysCmdObj.entrypoint((*keys.Keys).Add),
+	}
+	keysCmdObj.addKeyFieldFlags(addCmd.Flags())
+
+	// keys edit
+	editCmd := &cobra.Command{
+		Use:   "edit <query>",
+		Short: "Edit the fields of an OTP key",
+		Long: "Edit the fields of an OTP key. Only the fields given as flags are changed.\n\n" +
+			"The OTP key is looked up by its index (e.g. 3), issuer:label (e.g. GitHub:myuser), " +
+			"issuer, label or tag.",
+		Args: cobra.ExactArgs(1),
+
+		Run: keysCmdObj.entrypoint((*keys.Keys).Edit),
+	}
+	keysCmdObj.addKeyFieldFlags(editCmd.Flags())
+
+	// keys rm
+	rmCmd := &cobra.Command{
+		Use:   "rm <query>",
+		Short: "Remove an OTP key",
+		Args:  cobra.ExactArgs(1),
+
+		Run: keysCmdObj.entrypoint((*keys.Keys).Remove),
+	}
+
+	rmCmd.Flags().BoolVarP(
+		&keysCmdObj.keysConfig.Yes,
+		"yes", "y",
+		false,
+		"Do not ask for confirmation",
+	)
+
+	// keys tag
+	tagCmd := &cobra.Command{
+		Use:   "tag <query>",
+		Short: "Add or remove tags of an OTP key",
+		Args:  cobra.ExactArgs(1),
+
+		Run: keysCmdObj.entrypoint((*keys.Keys).Tag),
+	}
+
+	tagCmd.Flags().StringSliceVarP(
+		&keysCmdObj.keysConfig.AddTags,
+		"add", "a",
+		nil,
+		"Tags to add, can be repeated or comma-separated",
+	)
+
+	tagCmd.Flags().StringSliceVarP(
+		&keysCmdObj.keysConfig.RemoveTags,
+		"remove", "r",
+		nil,
+		"Tags to remove, can be repeated or comma-separated",
+	)
+
+	cmd.AddCommand(addCmd, editCmd, rmCmd, tagCmd)
+
+	return cmd
+}
+
+// addKeyFieldFlags adds the OTP key field flags of "keys add" and "keys edit"
+func (c *keysCmd) addKeyFieldFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.keysConfig.Issuer, "issuer", "", "Issuer of the OTP key")
+	flags.StringVar(&c.keysConfig.Label, "label", "", "Label of the OTP key")
+	flags.StringVar(&c.keysConfig.OTPType, "type", "TOTP", "Type of the OTP key: TOTP, HOTP or STEAM")
+	flags.StringVar(&c.keysConfig.Algorithm, "algorithm", "SHA1", "Algorithm of the OTP key: SHA1, SHA256, SHA512 or MD5")
+	flags.IntVar(&c.keysConfig.Digits, "digits", 6, "Number of digits of the OTP token")
+	flags.IntVar(&c.keysConfig.Period, "period", 30, "Period of the TOTP/Steam token in seconds")
+	flags.Int64Var(&c.keysConfig.Counter, "counter", 0, "Counter of the HOTP key")
+	flags.StringSliceVar(&c.keysConfig.Tags, "tag", nil, "Tags of the OTP key, can be repeated or comma-separated")
+
+	flags.StringVar(
+		&c.keysConfig.SecretSource,
+		"secret-source",
+		"prompt",
+		"Where to read the base32 OTP secret from, same format as --password-source. "+
+			"Prompts for the secret on \"keys add\" if not given.",
+	)
+}
+
+// entrypoint creates the entrypoint for a "keys" subcommand which runs the
+// given Keys method
+func (c *keysCmd) entrypoint(action func(*keys.Keys) error) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		memguard.CatchInterrupt()
+		defer memguard.Purge()
+
+		if len(args) > 0 {
+			c.keysConfig.Query = args[0]
+		}
+
+		c.keysConfig.ChangedFlags = make(map[string]bool)
+		cmd.Flags().Visit(func(flag *pflag.Flag) {
+			c.keysConfig.ChangedFlags[flag.Name] = true
+		})
+
+		keysConfig, err := keysconfig.ParseCmdConfig(c.config, c.keysConfig)
+		if err != nil {
+			log.Fatalf("error parsing/validating arguments: %v", err)
+		}
+
+		keys_, err := keys.NewKeys(keysConfig)
+		if err != nil {
+			log.Fatalf("error creating key manager: %v", err)
+		}
+
+		if err := action(keys_); err != nil {
+			log.Printf("error modifying OTP keys: %v", err)
+			memguard.SafeExit(1)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/awnumar/memguard"
+	"github.com/spf13/cobra"
+
+	"github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/qrcode"
+	qrcodeconfig "github.com/putrasattvika/andotp-cli/pkg/qrcode/config"
+)
+
+type qrCmd struct {
+	config   *config.Config
+	qrConfig *config.QRConfig
+}
+
+// newQRCmd creates a new "qr" command
+func newQRCmd(rootConfig *config.Config) *cobra.Command {
+	qrCmdObj := &qrCmd{
+		config:   rootConfig,
+		qrConfig: &config.QRConfig{},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "qr <query>",
+		Short: "Show the QR code of an OTP key to enroll it on another authenticator",
+		Long: "Show the QR code of an OTP key's otpauth:// URI on the terminal, or write it as a " +
+			"PNG image, to enroll the key on another authenticator.\n\n" +
+			"The OTP key is looked up by its index (e.g. 3), issuer:label (e.g. GitHub:myuser), " +
+			"issuer, label or tag, or fuzzily with --fuzzy if nothing matches exactly. The QR code " +
+			"contains the plaintext secret.",
+		Args: cobra.ExactArgs(1),
+
+		Run: qrCmdObj.entrypoint,
+	}
+
+	cmd.Flags().StringVar(
+		&qrCmdObj.qrConfig.PNGOutput,
+		"png",
+		"",
+		"Path to write the QR code as a PNG image to, instead of showing it on the terminal",
+	)
+
+	cmd.Flags().IntVar(
+		&qrCmdObj.qrConfig.PNGSize,
+		"png-size",
+		512,
+		"Width & height of the PNG image in pixels",
+	)
+
+	cmd.Flags().BoolVar(
+		&qrCmdObj.qrConfig.Invert,
+		"invert",
+		false,
+		"Invert the colors of the QR code, for terminals with a light background",
+	)
+
+	cmd.Flags().BoolVar(
+		&qrCmdObj.qrConfig.Fuzzy,
+		"fuzzy",
+		false,
+		"Use the best fuzzy match of the query if no OTP key matches it exactly",
+	)
+
+	return cmd
+}
+
+// Entrypoint for the "qr" command
+func (c *qrCmd) entrypoint(cmd *cobra.Command, args []string) {
+	memguard.CatchInterrupt()
+	defer memguard.Purge()
+
+	c.qrConfig.Query = args[0]
+
+	qrConfig, err := qrcodeconfig.ParseCmdConfig(c.config, c.qrConfig)
+	if err != nil {
+		log.Fatalf("error parsing/validating arguments: %v", err)
+	}
+
+	qrCode, err := qrcode.NewQRCode(qrConfig)
+	if err != nil {
+		log.Fatalf("error creating QR code renderer: %v", err)
+	}
+
+	if err := qrCode.Run(); err != nil {
+		log.Printf("error rendering QR code: %v", err)
+		memguard.SafeExit(1)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/putrasattvika/andotp-cli/cmd/config"
+	andotpbackup "github.com/putrasattvika/andotp-cli/pkg/andotp/backup"
 	"github.com/putrasattvika/andotp-cli/pkg/interactive"
 	interactiveconfig "github.com/putrasattvika/andotp-cli/pkg/interactive/config"
 )
@@ -19,13 +20,15 @@ import (
 const DebugArgsEnv = "DEBUG_ARGS"
 
 type rootCmd struct {
-	config *config.Config
+	config            *config.Config
+	interactiveConfig *config.InteractiveConfig
 }
 
 // newRootCmd creates a new "root" command group
 func newRootCmd() *cobra.Command {
 	rootCmdObj := &rootCmd{
-		config: &config.Config{},
+		config:            &config.Config{},
+		interactiveConfig: &config.InteractiveConfig{},
 	}
 
 	// The root command
@@ -42,12 +45,68 @@ func newRootCmd() *cobra.Command {
 		&rootCmdObj.config.BackupFileURI,
 		"backup-file-uri", "b",
 		"",
-		"URI to an andOTP backup file. Supports two sources: "+
-			"local file (e.g. file:///home/myuser/otp_accounts.json.aes) "+
-			"and KDE connect exposed device filesystem "+
-			"(e.g. kdeconnect://_/device-name-or-id/path/to/otp_accounts.json.aes)",
+		"URI to an andOTP backup file. Supports three sources: "+
+			"local file (e.g. file:///home/myuser/otp_accounts.json.aes), "+
+			"KDE connect exposed device filesystem "+
+			"(e.g. kdeconnect://_/device-name-or-id/path/to/otp_accounts.json.aes) "+
+			"and SFTP with ssh-agent or identity files and known_hosts verification "+
+			"(e.g. sftp://myuser@nas:22/~/backups/otp_accounts.json.aes?identity=~/.ssh/nas_ed25519)",
 	)
 
+	cmd.PersistentFlags().StringVarP(
+		&rootCmdObj.config.PasswordSource,
+		"password-source", "p",
+		"prompt",
+		"Where to read the backup password from: "+
+			"prompt (interactive prompt on the terminal), "+
+			"file:<path> (first line of a file), "+
+			"env:<variable> (environment variable), "+
+			"fd:<number> (first line of an open file descriptor, e.g. fd:0 for a password piped into stdin) or "+
+			"cmd:<shell command> (first line of a command's output, e.g. \"cmd:pass show andotp\")",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&rootCmdObj.config.BackupFormat,
+		"backup-format",
+		"",
+		"Format of the backup file, detected from its contents if empty. One of: "+
+			strings.Join(andotpbackup.AvailableFormats(), ", "),
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&rootCmdObj.config.PGPPrivateKey,
+		"pgp-private-key",
+		"",
+		"Path to an armored or binary OpenPGP private key to decrypt "+andotpbackup.FormatAndOTPPGP+" backups with, "+
+			"unlocked with the backup password. If empty, backups are decrypted by running the gpg binary, "+
+			"which must be installed and reaches gpg-agent for the GnuPG keyring's key, and can't be written back",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&rootCmdObj.config.ClipboardClearAfter,
+		"clipboard-clear-after",
+		"period",
+		"When to clear a token copied to the clipboard, if it's still there: "+
+			"period (when the token expires, 30s for HOTP keys), never, or a duration such as 45s",
+	)
+
+	cmd.Flags().StringSliceVarP(
+		&rootCmdObj.interactiveConfig.Tags,
+		"tag", "t",
+		nil,
+		"Only show OTP keys having any of these tags in the interactive shell, can be repeated",
+	)
+
+	// Subcommands
+	cmd.AddCommand(newCodeCmd(rootCmdObj.config))
+	cmd.AddCommand(newKeysCmd(rootCmdObj.config))
+	cmd.AddCommand(newExportCmd(rootCmdObj.config))
+	cmd.AddCommand(newImportCmd(rootCmdObj.config))
+	cmd.AddCommand(newQRCmd(rootCmdObj.config))
+	cmd.AddCommand(newTUICmd(rootCmdObj.config))
+	cmd.AddCommand(newDevicesCmd())
+	cmd.AddCommand(newClipboardHelperCmd())
+
 	return cmd
 }
 
@@ -58,7 +117,7 @@ func (c *rootCmd) entrypoint(cmd *cobra.Command, args []string) {
 	memguard.CatchInterrupt()
 	defer memguard.Purge()
 
-	interactiveConfig, err := interactiveconfig.ParseCmdConfig(c.config)
+	interactiveConfig, err := interactiveconfig.ParseCmdConfig(c.config, c.interactiveConfig)
 	if err != nil {
 		log.Fatalf("error parsing/validating arguments: %v", err)
 	}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/awnumar/memguard"
+	"github.com/spf13/cobra"
+
+	"github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/tui"
+	tuiconfig "github.com/putrasattvika/andotp-cli/pkg/tui/config"
+)
+
+type tuiCmd struct {
+	config *config.Config
+}
+
+// newTUICmd creates a new "tui" command
+func newTUICmd(rootConfig *config.Config) *cobra.Command {
+	tuiCmdObj := &tuiCmd{
+		config: rootConfig,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "tui",
+		Short: "Full-screen dashboard with the live tokens of every OTP key",
+		Long: "Full-screen dashboard with the live tokens of every OTP key, refreshed every second.\n\n" +
+			"Type to filter the OTP keys by issuer, label or tag, use the up/down arrows to select " +
+			"one and press enter to copy its token to the clipboard. HOTP tokens are only generated " +
+			"when pressing enter. Press esc to clear the filter or quit.",
+		Args: cobra.NoArgs,
+
+		Run: tuiCmdObj.entrypoint,
+	}
+
+	return cmd
+}
+
+// Entrypoint for the "tui" command
+func (c *tuiCmd) entrypoint(cmd *cobra.Command, args []string) {
+	memguard.CatchInterrupt()
+	defer memguard.Purge()
+
+	tuiConfig, err := tuiconfig.ParseCmdConfig(c.config)
+	if err != nil {
+		log.Fatalf("error parsing/validating arguments: %v", err)
+	}
+
+	tui_, err := tui.NewTUI(tuiConfig)
+	if err != nil {
+		log.Fatalf("error creating dashboard: %v", err)
+	}
+
+	if err := tui_.Start(); err != nil {
+		log.Printf("error running dashboard: %v", err)
+		memguard.SafeExit(1)
+	}
+}
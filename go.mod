@@ -3,14 +3,20 @@ module github.com/putrasattvika/andotp-cli
 go 1.16
 
 require (
+	github.com/ProtonMail/go-crypto v0.0.0-20210428141323-04723f9f07d7
 	github.com/atotto/clipboard v0.1.4
 	github.com/awnumar/memguard v0.22.2
+	github.com/boombuler/barcode v1.0.1-0.20190219062509-6c824513bacc
 	github.com/c-bata/go-prompt v0.2.6
+	github.com/godbus/dbus/v5 v5.0.4
 	github.com/grijul/go-andotp v1.0.23
 	github.com/pkg/errors v0.9.1
 	github.com/pkg/sftp v1.13.2
 	github.com/pquerna/otp v1.3.0
 	github.com/spf13/cobra v1.2.1
+	github.com/spf13/pflag v1.0.5
 	golang.org/x/crypto v0.0.0-20210506145944-38f3c27a63bf
 	golang.org/x/term v0.0.0-20210503060354-a79de5458b56
+	google.golang.org/protobuf v1.26.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,243 @@
+package aegis
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// decryptDatabase decrypts the database of an encrypted vault. The master key
+// is decrypted from the first password slot that accepts the password.
+func decryptDatabase(v *vault, password *memguard.LockedBuffer) ([]byte, error) {
+	masterKey, err := unlockMasterKey(v, password)
+	if err != nil {
+		return nil, err
+	}
+
+	defer memguardcore.Wipe(masterKey)
+
+	return openDatabase(v, masterKey)
+}
+
+// unlockMasterKey decrypts the master key of an encrypted vault from the first
+// password slot that accepts the password
+func unlockMasterKey(v *vault, password *memguard.LockedBuffer) ([]byte, error) {
+	if v.Header.Params == nil {
+		return nil, errors.New("encrypted Aegis vault has no database parameters")
+	}
+
+	var masterKey []byte
+	passwordSlots := 0
+
+	for _, s := range v.Header.Slots {
+		if s.Type != slotTypePassword {
+			continue
+		}
+
+		passwordSlots++
+
+		key, err := decryptSlot(s, password)
+		if err == nil {
+			masterKey = key
+			break
+		}
+	}
+
+	if passwordSlots == 0 {
+		return nil, errors.New("encrypted Aegis vault has no password slot")
+	}
+
+	if masterKey == nil {
+		return nil, errors.New("unable to decrypt Aegis vault master key, wrong password?")
+	}
+
+	return masterKey, nil
+}
+
+// openDatabase decrypts the database of an encrypted vault with its master key
+func openDatabase(v *vault, masterKey []byte) ([]byte, error) {
+	var dbCiphertext string
+	if err := json.Unmarshal(v.DB, &dbCiphertext); err != nil {
+		return nil, errors.Wrap(err, "invalid encrypted Aegis vault database")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(dbCiphertext)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid encrypted Aegis vault database")
+	}
+
+	plaintext, err := openAESGCM(masterKey, *v.Header.Params, ciphertext)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt Aegis vault database")
+	}
+
+	return plaintext, nil
+}
+
+// sealDatabase encrypts the database JSON with the master key, returning the
+// vault's db field and the database parameters of its header
+func sealDatabase(masterKey []byte, dbJSON []byte) (json.RawMessage, keyParams, error) {
+	encryptedDB, dbParams, err := sealAESGCM(masterKey, dbJSON)
+	if err != nil {
+		return nil, keyParams{}, errors.Wrap(err, "error encrypting Aegis vault database")
+	}
+
+	dbCiphertext, err := json.Marshal(base64.StdEncoding.EncodeToString(encryptedDB))
+	if err != nil {
+		return nil, keyParams{}, errors.Wrap(err, "error serializing encrypted Aegis vault database")
+	}
+
+	return dbCiphertext, dbParams, nil
+}
+
+// decryptSlot decrypts the master key from a password slot
+func decryptSlot(s slot, password *memguard.LockedBuffer) ([]byte, error) {
+	salt, err := hex.DecodeString(s.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis slot salt")
+	}
+
+	encryptedKey, err := hex.DecodeString(s.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis slot key")
+	}
+
+	derivedKey, err := scrypt.Key(password.Bytes(), salt, s.N, s.R, s.P, masterKeyLen)
+	if err != nil {
+		return nil, errors.Wrap(err, "error deriving Aegis slot key")
+	}
+
+	defer memguardcore.Wipe(derivedKey)
+
+	return openAESGCM(derivedKey, s.KeyParams, encryptedKey)
+}
+
+// encryptDatabase encrypts the database JSON with a new master key, which is
+// then stored in a single new password slot
+func encryptDatabase(v *vault, dbJSON []byte, password *memguard.LockedBuffer) error {
+	masterKey := make([]byte, masterKeyLen)
+	if _, err := rand.Read(masterKey); err != nil {
+		return errors.Wrap(err, "error generating Aegis vault master key")
+	}
+
+	defer memguardcore.Wipe(masterKey)
+
+	salt := make([]byte, scryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.Wrap(err, "error generating Aegis slot salt")
+	}
+
+	derivedKey, err := scrypt.Key(password.Bytes(), salt, scryptN, scryptR, scryptP, masterKeyLen)
+	if err != nil {
+		return errors.Wrap(err, "error deriving Aegis slot key")
+	}
+
+	defer memguardcore.Wipe(derivedKey)
+
+	encryptedKey, slotParams, err := sealAESGCM(derivedKey, masterKey)
+	if err != nil {
+		return errors.Wrap(err, "error encrypting Aegis vault master key")
+	}
+
+	dbCiphertext, dbParams, err := sealDatabase(masterKey, dbJSON)
+	if err != nil {
+		return err
+	}
+
+	v.Header = header{
+		Slots: []slot{{
+			Type:      slotTypePassword,
+			UUID:      newUUID(),
+			Key:       hex.EncodeToString(encryptedKey),
+			KeyParams: slotParams,
+			N:         scryptN,
+			R:         scryptR,
+			P:         scryptP,
+			Salt:      hex.EncodeToString(salt),
+			Repaired:  true,
+		}},
+		Params: &dbParams,
+	}
+	v.DB = dbCiphertext
+
+	return nil
+}
+
+// openAESGCM decrypts an AES-GCM ciphertext whose tag is stored separately
+func openAESGCM(key []byte, params keyParams, ciphertext []byte) ([]byte, error) {
+	nonce, err := hex.DecodeString(params.Nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid nonce")
+	}
+
+	tag, err := hex.DecodeString(params.Tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tag")
+	}
+
+	aesgcm, err := newAESGCM(key, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(sealed, ciphertext...)
+	sealed = append(sealed, tag...)
+
+	return aesgcm.Open(nil, nonce, sealed, nil)
+}
+
+// sealAESGCM encrypts the plaintext with AES-GCM and a random nonce, returning
+// the ciphertext and its tag separately
+func sealAESGCM(key []byte, plaintext []byte) ([]byte, keyParams, error) {
+	nonce := make([]byte, nonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, keyParams{}, errors.Wrap(err, "error generating nonce")
+	}
+
+	aesgcm, err := newAESGCM(key, nonceLen)
+	if err != nil {
+		return nil, keyParams{}, err
+	}
+
+	sealed := aesgcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext, tag := sealed[:len(sealed)-tagLen], sealed[len(sealed)-tagLen:]
+
+	return ciphertext, keyParams{Nonce: hex.EncodeToString(nonce), Tag: hex.EncodeToString(tag)}, nil
+}
+
+func newAESGCM(key []byte, nonceSize int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AES cipher")
+	}
+
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, nonceSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AES-GCM cipher")
+	}
+
+	return aesgcm, nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	u := make([]byte, 16)
+	if _, err := rand.Read(u); err != nil {
+		memguard.SafePanic(err)
+	}
+
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
@@ -0,0 +1,342 @@
+package aegis
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// rawObject is a JSON object whose fields are kept as-is unless replaced, so
+// that fields unknown to andotp-cli survive a write-back
+type rawObject map[string]json.RawMessage
+
+// Update writes OTP keys back into the Aegis vault they were decoded from.
+//
+// Entries are matched to OTP keys by their UUID (OTPKey.SourceID). Unchanged
+// entries are kept byte for byte, changed entries only get the fields andOTP
+// knows about replaced, keeping their icon, note, favorite flag and UUID.
+// Entries of removed OTP keys are dropped and new OTP keys are added as new
+// entries. The vault's header, i.e. its slots, is kept and an encrypted
+// database is re-encrypted with the existing master key, unlocked with the
+// password the vault was decrypted with.
+func Update(original []byte, otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	v := &vault{}
+	if err := json.Unmarshal(original, v); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault")
+	}
+
+	rawVault := rawObject{}
+	if err := json.Unmarshal(original, &rawVault); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault")
+	}
+
+	encrypted := len(v.Header.Slots) > 0
+	dbJSON := []byte(v.DB)
+
+	var masterKey []byte
+
+	if encrypted {
+		if password == nil {
+			return nil, errors.New("Aegis vault is encrypted but no password was given")
+		}
+
+		var err error
+
+		masterKey, err = unlockMasterKey(v, password)
+		if err != nil {
+			return nil, err
+		}
+
+		defer memguardcore.Wipe(masterKey)
+
+		dbJSON, err = openDatabase(v, masterKey)
+		if err != nil {
+			return nil, err
+		}
+
+		defer memguardcore.Wipe(dbJSON)
+	}
+
+	newDBJSON, err := updateDatabase(dbJSON, otpKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	defer memguardcore.Wipe(newDBJSON)
+
+	if encrypted {
+		dbCiphertext, dbParams, err := sealDatabase(masterKey, newDBJSON)
+		if err != nil {
+			return nil, err
+		}
+
+		rawHeader := rawObject{}
+		if err := json.Unmarshal(rawVault["header"], &rawHeader); err != nil {
+			return nil, errors.Wrap(err, "invalid Aegis vault header")
+		}
+
+		if rawHeader["params"], err = json.Marshal(dbParams); err != nil {
+			return nil, errors.Wrap(err, "error serializing Aegis vault header")
+		}
+
+		if rawVault["header"], err = json.Marshal(rawHeader); err != nil {
+			return nil, errors.Wrap(err, "error serializing Aegis vault header")
+		}
+
+		rawVault["db"] = dbCiphertext
+	} else {
+		rawVault["db"] = newDBJSON
+	}
+
+	var out bytes.Buffer
+
+	encoder := json.NewEncoder(&out)
+	encoder.SetIndent("", "    ")
+
+	if err := encoder.Encode(rawVault); err != nil {
+		return nil, errors.Wrap(err, "error serializing Aegis vault")
+	}
+
+	return out.Bytes(), nil
+}
+
+// updateDatabase writes the OTP keys into the plaintext database JSON
+func updateDatabase(dbJSON []byte, otpKeys []*otp.OTPKey) ([]byte, error) {
+	db := &database{}
+	if err := json.Unmarshal(dbJSON, db); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault database")
+	}
+
+	rawDB := rawObject{}
+	if err := json.Unmarshal(dbJSON, &rawDB); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault database")
+	}
+
+	var rawEntries []json.RawMessage
+	if err := json.Unmarshal(rawDB["entries"], &rawEntries); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault entries")
+	}
+
+	if len(rawEntries) != len(db.Entries) {
+		return nil, errors.New("invalid Aegis vault entries")
+	}
+
+	groups := newGroupIndex(db)
+
+	entryIndexes := make(map[string]int)
+	for idx, e := range db.Entries {
+		entryIndexes[e.UUID] = idx
+	}
+
+	entries := make([]json.RawMessage, 0, len(otpKeys))
+
+	for _, otpKey := range otpKeys {
+		var rawEntry json.RawMessage
+		var err error
+
+		if idx, ok := entryIndexes[otpKey.SourceID]; ok && otpKey.SourceID != "" {
+			rawEntry, err = updateEntry(rawEntries[idx], db.Entries[idx], otpKey, groups)
+		} else {
+			rawEntry, err = addEntry(otpKey, groups)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, rawEntry)
+	}
+
+	var err error
+
+	if rawDB["entries"], err = json.Marshal(entries); err != nil {
+		return nil, errors.Wrap(err, "error serializing Aegis vault entries")
+	}
+
+	if groups.changed {
+		if rawDB["groups"], err = json.Marshal(db.Groups); err != nil {
+			return nil, errors.Wrap(err, "error serializing Aegis vault groups")
+		}
+	}
+
+	newDBJSON, err := json.Marshal(rawDB)
+
+	// Run GC to remove the plaintext secrets from memory
+	db = nil
+	rawDB = nil
+	rawEntries = nil
+	entries = nil
+	runtime.GC()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing Aegis vault database")
+	}
+
+	return newDBJSON, nil
+}
+
+// groupIndex maps tags to the groups of a database, adding groups for new tags
+type groupIndex struct {
+	db      *database
+	uuids   map[string]string
+	names   map[string]string
+	changed bool
+}
+
+func newGroupIndex(db *database) *groupIndex {
+	g := &groupIndex{db: db, uuids: make(map[string]string), names: make(map[string]string)}
+
+	for _, dbGroup := range db.Groups {
+		g.uuids[dbGroup.Name] = dbGroup.UUID
+		g.names[dbGroup.UUID] = dbGroup.Name
+	}
+
+	return g
+}
+
+// groupsOf returns the entry group fields for the tags: the group UUIDs since
+// database version 3, the first tag before
+func (g *groupIndex) groupsOf(tags []string) (string, []string) {
+	if g.db.Version < 3 {
+		if len(tags) == 0 {
+			return "", nil
+		}
+
+		return tags[0], nil
+	}
+
+	uuids := []string{}
+
+	for _, tag := range tags {
+		if _, ok := g.uuids[tag]; !ok {
+			g.uuids[tag] = newUUID()
+			g.names[g.uuids[tag]] = tag
+			g.db.Groups = append(g.db.Groups, group{UUID: g.uuids[tag], Name: tag})
+			g.changed = true
+		}
+
+		uuids = append(uuids, g.uuids[tag])
+	}
+
+	return "", uuids
+}
+
+// updateEntry writes the OTP key into its original entry. The entry is kept
+// as-is if the OTP key didn't change.
+func updateEntry(rawEntry json.RawMessage, e entry, otpKey *otp.OTPKey, groups *groupIndex) (json.RawMessage, error) {
+	originalKey, err := entryToOTPKey(e, groups.names)
+	if err != nil {
+		return nil, err
+	}
+
+	if sameKey(originalKey, otpKey) {
+		return rawEntry, nil
+	}
+
+	updated, err := newEntry(otpKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := rawObject{}
+	if err := json.Unmarshal(rawEntry, &fields); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault entry")
+	}
+
+	info := rawObject{}
+	if err := json.Unmarshal(fields["info"], &info); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault entry")
+	}
+
+	set := func(object rawObject, field string, value interface{}) {
+		if err == nil {
+			object[field], err = json.Marshal(value)
+		}
+	}
+
+	set(fields, "type", updated.Type)
+	set(fields, "name", updated.Name)
+	set(fields, "issuer", updated.Issuer)
+
+	set(info, "secret", updated.Info.Secret)
+	set(info, "algo", updated.Info.Algo)
+	set(info, "digits", updated.Info.Digits)
+
+	if updated.Info.Counter != nil {
+		set(info, "counter", *updated.Info.Counter)
+		delete(info, "period")
+	} else {
+		set(info, "period", updated.Info.Period)
+		delete(info, "counter")
+	}
+
+	set(fields, "info", info)
+
+	if group, groupUUIDs := groups.groupsOf(otpKey.Tags); groupUUIDs != nil {
+		set(fields, "groups", groupUUIDs)
+	} else {
+		set(fields, "group", group)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing Aegis vault entry")
+	}
+
+	return json.Marshal(fields)
+}
+
+// addEntry creates a new entry for the OTP key
+func addEntry(otpKey *otp.OTPKey, groups *groupIndex) (json.RawMessage, error) {
+	e, err := newEntry(otpKey)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Group, e.Groups = groups.groupsOf(otpKey.Tags)
+
+	rawEntry, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing Aegis vault entry")
+	}
+
+	return rawEntry, nil
+}
+
+// sameKey returns true if both OTP keys have the same fields stored in an
+// Aegis entry
+func sameKey(a *otp.OTPKey, b *otp.OTPKey) bool {
+	if a.Issuer != b.Issuer || a.Label != b.Label || a.OTPType != b.OTPType ||
+		a.AlgorithmStr != b.AlgorithmStr || a.DigitsInt != b.DigitsInt {
+		return false
+	}
+
+	if a.OTPType == "HOTP" && a.Counter != b.Counter {
+		return false
+	}
+
+	if a.OTPType != "HOTP" && a.Period != b.Period {
+		return false
+	}
+
+	if len(a.Tags) != len(b.Tags) {
+		return false
+	}
+
+	for idx := range a.Tags {
+		if a.Tags[idx] != b.Tags[idx] {
+			return false
+		}
+	}
+
+	aSecret, bSecret := a.SecretBuffer(), b.SecretBuffer()
+	defer aSecret.Destroy()
+	defer bSecret.Destroy()
+
+	return bytes.Equal(aSecret.Bytes(), bSecret.Bytes())
+}
@@ -0,0 +1,217 @@
+package aegis
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/awnumar/memguard"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+const updateTestVault = `{
+    "version": 1,
+    "header": {"slots": null, "params": null},
+    "db": {
+        "version": 3,
+        "icons_optimized": true,
+        "entries": [
+            {
+                "type": "totp",
+                "uuid": "6c7d1a4e-0000-4000-8000-000000000001",
+                "name": "me@example.com",
+                "issuer": "GitHub",
+                "note": "recovery codes in the safe",
+                "favorite": true,
+                "icon": "iVBORw0KGgo=",
+                "icon_mime": "image/png",
+                "info": {"secret": "JBSWY3DPEHPK3PXP", "algo": "SHA1", "digits": 6, "period": 30},
+                "groups": ["6c7d1a4e-0000-4000-8000-0000000000a1"]
+            },
+            {
+                "type": "hotp",
+                "uuid": "6c7d1a4e-0000-4000-8000-000000000002",
+                "name": "token",
+                "issuer": "Acme",
+                "note": "hardware token replacement",
+                "favorite": false,
+                "icon": null,
+                "info": {"secret": "GEZDGNBVGY3TQOJQ", "algo": "SHA1", "digits": 6, "counter": 7},
+                "groups": []
+            }
+        ],
+        "groups": [{"uuid": "6c7d1a4e-0000-4000-8000-0000000000a1", "name": "work"}]
+    }
+}`
+
+// decodeRaw decodes a JSON object keeping its fields raw
+func decodeRaw(t *testing.T, content []byte) rawObject {
+	t.Helper()
+
+	object := rawObject{}
+	if err := json.Unmarshal(content, &object); err != nil {
+		t.Fatalf("invalid JSON object: %v", err)
+	}
+
+	return object
+}
+
+// rawEntries returns the raw entries of a plaintext vault
+func rawEntries(t *testing.T, vaultJSON []byte) []rawObject {
+	t.Helper()
+
+	var entries []rawObject
+	if err := json.Unmarshal(decodeRaw(t, decodeRaw(t, vaultJSON)["db"])["entries"], &entries); err != nil {
+		t.Fatalf("invalid vault entries: %v", err)
+	}
+
+	return entries
+}
+
+func TestUpdatePlaintext(t *testing.T) {
+	original := []byte(updateTestVault)
+
+	otpKeys, err := Decode(original, nil)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	// Advance the HOTP counter, remove nothing, add a new key
+	otpKeys[1].Counter++
+
+	newKey := &otp.OTPKey{
+		Issuer: "Steam", Label: "gamer", OTPType: "TOTP", AlgorithmStr: "SHA1",
+		DigitsInt: 6, Period: 30, Tags: []string{"games"},
+	}
+	newKey.SetSecret([]byte("MFRGGZDFMZTWQ2LK"))
+
+	if err := newKey.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	updated, err := Update(original, append(otpKeys, newKey), nil)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	originalEntries := rawEntries(t, original)
+	entries := rawEntries(t, updated)
+
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	// The unchanged entry is kept with all its fields
+	for field, value := range originalEntries[0] {
+		var want, got bytes.Buffer
+		json.Compact(&want, value)
+		json.Compact(&got, entries[0][field])
+
+		if want.String() != got.String() {
+			t.Errorf("unchanged entry field %s = %s, want %s", field, got.String(), want.String())
+		}
+	}
+
+	// The changed entry keeps its UUID and note, with the new counter
+	var changed struct {
+		UUID string `json:"uuid"`
+		Note string `json:"note"`
+		Info struct {
+			Counter int64 `json:"counter"`
+		} `json:"info"`
+	}
+
+	changedJSON, _ := json.Marshal(entries[1])
+	if err := json.Unmarshal(changedJSON, &changed); err != nil {
+		t.Fatalf("invalid changed entry: %v", err)
+	}
+
+	if changed.UUID != "6c7d1a4e-0000-4000-8000-000000000002" || changed.Note != "hardware token replacement" {
+		t.Errorf("changed entry lost its fields: %s", changedJSON)
+	}
+
+	if changed.Info.Counter != 8 {
+		t.Errorf("changed entry counter = %d, want 8", changed.Info.Counter)
+	}
+
+	// Unknown database fields are kept, the new tag becomes a new group
+	db := decodeRaw(t, decodeRaw(t, updated)["db"])
+	if string(db["icons_optimized"]) != "true" {
+		t.Errorf("database lost icons_optimized: %s", db["icons_optimized"])
+	}
+
+	reloaded, err := Decode(updated, nil)
+	if err != nil {
+		t.Fatalf("Decode() of the updated vault error = %v", err)
+	}
+
+	if len(reloaded) != 3 || len(reloaded[2].Tags) != 1 || reloaded[2].Tags[0] != "games" {
+		t.Errorf("new key not added with its tag: %+v", reloaded)
+	}
+}
+
+func TestUpdateEncrypted(t *testing.T) {
+	// Kept referenced until the end, memguard destroys unreachable buffers
+	password := memguard.NewBufferFromBytes([]byte("hunter2"))
+	defer password.Destroy()
+
+	wrongPassword := memguard.NewBufferFromBytes([]byte("wrong"))
+	defer wrongPassword.Destroy()
+
+	plaintextKeys, err := Decode([]byte(updateTestVault), nil)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	encrypted, err := Encode(plaintextKeys, password)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// Add a biometric slot, which can't be recreated without the device
+	v := decodeRaw(t, encrypted)
+	header := decodeRaw(t, v["header"])
+
+	var slots []json.RawMessage
+	json.Unmarshal(header["slots"], &slots)
+	slots = append(slots, json.RawMessage(`{"type":2,"uuid":"biometric","id":"aGVsbG8=","key":"00","key_params":{"nonce":"00","tag":"00"}}`))
+	header["slots"], _ = json.Marshal(slots)
+	v["header"], _ = json.Marshal(header)
+	original, _ := json.Marshal(v)
+
+	otpKeys, err := Decode(original, password)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	otpKeys[1].Counter++
+
+	updated, err := Update(original, otpKeys, password)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	updatedHeader := decodeRaw(t, decodeRaw(t, updated)["header"])
+
+	var want, got bytes.Buffer
+	json.Compact(&want, header["slots"])
+	json.Compact(&got, updatedHeader["slots"])
+
+	if want.String() != got.String() {
+		t.Errorf("slots changed:\n got %s\nwant %s", got.String(), want.String())
+	}
+
+	reloaded, err := Decode(updated, password)
+	if err != nil {
+		t.Fatalf("Decode() of the updated vault error = %v", err)
+	}
+
+	if reloaded[1].Counter != 8 {
+		t.Errorf("counter = %d, want 8", reloaded[1].Counter)
+	}
+
+	if _, err := Update(original, otpKeys, wrongPassword); err == nil {
+		t.Errorf("Update() with a wrong password succeeded")
+	}
+}
@@ -0,0 +1,331 @@
+package aegis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// Vault format & database versions written by Encode
+const (
+	vaultVersion = 1
+	dbVersion    = 3
+)
+
+// Password slot parameters, same as Aegis' defaults
+const (
+	slotTypePassword = 1
+
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptSaltLen = 32
+
+	masterKeyLen = 32
+	nonceLen     = 12
+	tagLen       = 16
+)
+
+// Mapping between Aegis entry type and andOTP OTP type
+var entryTypeMapping = map[string]string{
+	"totp":  "TOTP",
+	"hotp":  "HOTP",
+	"steam": "STEAM",
+}
+
+// vault is the top-level structure of an Aegis vault export. The db field is
+// either a database object (plaintext vault) or a base64-encoded encrypted
+// database (encrypted vault).
+//
+// Ref: https://github.com/beemdevelopment/Aegis/blob/master/docs/vault.md
+type vault struct {
+	Version int             `json:"version"`
+	Header  header          `json:"header"`
+	DB      json.RawMessage `json:"db"`
+}
+
+type header struct {
+	Slots  []slot     `json:"slots"`
+	Params *keyParams `json:"params"`
+}
+
+type slot struct {
+	Type      int       `json:"type"`
+	UUID      string    `json:"uuid"`
+	Key       string    `json:"key"`
+	KeyParams keyParams `json:"key_params"`
+
+	// Password slot fields
+	N        int    `json:"n,omitempty"`
+	R        int    `json:"r,omitempty"`
+	P        int    `json:"p,omitempty"`
+	Salt     string `json:"salt,omitempty"`
+	Repaired bool   `json:"repaired,omitempty"`
+}
+
+type keyParams struct {
+	Nonce string `json:"nonce"`
+	Tag   string `json:"tag"`
+}
+
+type database struct {
+	Version int     `json:"version"`
+	Entries []entry `json:"entries"`
+
+	// Since database version 3, entries refer to groups by UUID
+	Groups []group `json:"groups,omitempty"`
+}
+
+type group struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+type entry struct {
+	Type     string    `json:"type"`
+	UUID     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Issuer   string    `json:"issuer"`
+	Note     string    `json:"note"`
+	Favorite bool      `json:"favorite"`
+	Icon     *string   `json:"icon"`
+	Info     entryInfo `json:"info"`
+
+	// Database version 2 has a single group name per entry, version 3 has a
+	// list of group UUIDs
+	Group  string   `json:"group,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+type entryInfo struct {
+	Secret  string `json:"secret"`
+	Algo    string `json:"algo"`
+	Digits  int    `json:"digits"`
+	Period  int    `json:"period,omitempty"`
+	Counter *int64 `json:"counter,omitempty"`
+}
+
+// IsVault returns true if the content looks like an Aegis vault export
+func IsVault(content []byte) bool {
+	var v struct {
+		Header *json.RawMessage `json:"header"`
+		DB     *json.RawMessage `json:"db"`
+	}
+
+	if err := json.Unmarshal(content, &v); err != nil {
+		return false
+	}
+
+	return v.Header != nil && v.DB != nil
+}
+
+// IsEncrypted returns true if the Aegis vault's database is encrypted
+func IsEncrypted(content []byte) (bool, error) {
+	v := &vault{}
+	if err := json.Unmarshal(content, v); err != nil {
+		return false, errors.Wrap(err, "invalid Aegis vault")
+	}
+
+	return len(v.Header.Slots) > 0, nil
+}
+
+// Decode parses the OTP keys of an Aegis vault. The password is only used for
+// encrypted vaults and may be nil for plaintext ones.
+func Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	v := &vault{}
+	if err := json.Unmarshal(content, v); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault")
+	}
+
+	dbJSON := []byte(v.DB)
+
+	if len(v.Header.Slots) > 0 {
+		if password == nil {
+			return nil, errors.New("Aegis vault is encrypted but no password was given")
+		}
+
+		decrypted, err := decryptDatabase(v, password)
+		if err != nil {
+			return nil, err
+		}
+
+		defer memguardcore.Wipe(decrypted)
+
+		dbJSON = decrypted
+	}
+
+	db := &database{}
+	if err := json.Unmarshal(dbJSON, db); err != nil {
+		return nil, errors.Wrap(err, "invalid Aegis vault database")
+	}
+
+	groupNames := make(map[string]string)
+	for _, g := range db.Groups {
+		groupNames[g.UUID] = g.Name
+	}
+
+	otpKeys := make([]*otp.OTPKey, 0, len(db.Entries))
+
+	for _, e := range db.Entries {
+		otpKey, err := entryToOTPKey(e, groupNames)
+		if err != nil {
+			return nil, err
+		}
+
+		otpKeys = append(otpKeys, otpKey)
+	}
+
+	// Run GC to remove the plaintext secrets from memory
+	db = nil
+	runtime.GC()
+
+	return otpKeys, nil
+}
+
+// entryToOTPKey converts an Aegis entry into an OTP key. Its groups become
+// tags.
+func entryToOTPKey(e entry, groupNames map[string]string) (*otp.OTPKey, error) {
+	otpType, ok := entryTypeMapping[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported Aegis entry type '%s' for '%s:%s'", e.Type, e.Issuer, e.Name)
+	}
+
+	otpKey := &otp.OTPKey{
+		Issuer:       e.Issuer,
+		Label:        e.Name,
+		OTPType:      otpType,
+		AlgorithmStr: strings.ToUpper(e.Info.Algo),
+		DigitsInt:    e.Info.Digits,
+		Period:       e.Info.Period,
+		Tags:         []string{},
+		Thumbnail:    "Default",
+		SourceID:     e.UUID,
+	}
+
+	if e.Info.Counter != nil {
+		otpKey.Counter = *e.Info.Counter
+	}
+
+	// Aegis groups become andOTP tags
+	if e.Group != "" {
+		otpKey.Tags = append(otpKey.Tags, e.Group)
+	}
+
+	for _, groupUUID := range e.Groups {
+		if name, ok := groupNames[groupUUID]; ok {
+			otpKey.Tags = append(otpKey.Tags, name)
+		}
+	}
+
+	otpKey.SetSecret([]byte(e.Info.Secret))
+
+	if err := otpKey.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid Aegis entry '%s:%s'", e.Issuer, e.Name)
+	}
+
+	return otpKey, nil
+}
+
+// Encode serializes OTP keys into an Aegis vault, importable into Aegis. The
+// vault is encrypted with a single password slot if password is non-nil.
+func Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	db := &database{Version: dbVersion, Entries: []entry{}, Groups: []group{}}
+	groupUUIDs := make(map[string]string)
+
+	for _, otpKey := range otpKeys {
+		e, err := newEntry(otpKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// andOTP tags become Aegis groups
+		for _, tag := range otpKey.Tags {
+			if _, ok := groupUUIDs[tag]; !ok {
+				groupUUIDs[tag] = newUUID()
+				db.Groups = append(db.Groups, group{UUID: groupUUIDs[tag], Name: tag})
+			}
+
+			e.Groups = append(e.Groups, groupUUIDs[tag])
+		}
+
+		db.Entries = append(db.Entries, e)
+	}
+
+	dbJSON, err := json.Marshal(db)
+
+	// Run GC to remove the plaintext secrets from memory
+	db = nil
+	runtime.GC()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing Aegis vault database")
+	}
+
+	defer memguardcore.Wipe(dbJSON)
+
+	v := &vault{Version: vaultVersion}
+
+	if password == nil {
+		v.DB = dbJSON
+	} else if err := encryptDatabase(v, dbJSON, password); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+
+	encoder := json.NewEncoder(&out)
+	encoder.SetIndent("", "    ")
+
+	if err := encoder.Encode(v); err != nil {
+		return nil, errors.Wrap(err, "error serializing Aegis vault")
+	}
+
+	return out.Bytes(), nil
+}
+
+// newEntry converts an OTP key into an Aegis entry, without its groups
+func newEntry(otpKey *otp.OTPKey) (entry, error) {
+	entryType := ""
+	for aegisType, otpType := range entryTypeMapping {
+		if otpType == otpKey.OTPType {
+			entryType = aegisType
+		}
+	}
+
+	if entryType == "" {
+		return entry{}, fmt.Errorf("unsupported OTP type '%s' for '%s:%s'", otpKey.OTPType, otpKey.Issuer, otpKey.Label)
+	}
+
+	secretBuf := otpKey.SecretBuffer()
+	secret := string(secretBuf.Bytes())
+	secretBuf.Destroy()
+
+	e := entry{
+		Type:   entryType,
+		UUID:   newUUID(),
+		Name:   otpKey.Label,
+		Issuer: otpKey.Issuer,
+		Info: entryInfo{
+			Secret: secret,
+			Algo:   otpKey.AlgorithmStr,
+			Digits: otpKey.DigitsInt,
+		},
+	}
+
+	if otpKey.OTPType == "HOTP" {
+		counter := otpKey.Counter
+		e.Info.Counter = &counter
+	} else {
+		e.Info.Period = otpKey.Period
+	}
+
+	return e, nil
+}
@@ -0,0 +1,186 @@
+package aegis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/awnumar/memguard"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// keyFields are the fields of an OTP key stored in a vault, with its
+// plaintext secret
+type keyFields struct {
+	Issuer    string
+	Label     string
+	OTPType   string
+	Algorithm string
+	Digits    int
+	Period    int
+	Counter   int64
+	Tags      []string
+	Secret    string
+}
+
+func fieldsOf(k *otp.OTPKey) keyFields {
+	secretBuf := k.SecretBuffer()
+	defer secretBuf.Destroy()
+
+	fields := keyFields{
+		Issuer:    k.Issuer,
+		Label:     k.Label,
+		OTPType:   k.OTPType,
+		Algorithm: k.AlgorithmStr,
+		Digits:    k.DigitsInt,
+		Tags:      k.Tags,
+		Secret:    string(secretBuf.Bytes()),
+	}
+
+	if k.OTPType == "HOTP" {
+		fields.Counter = k.Counter
+	} else {
+		fields.Period = k.Period
+	}
+
+	return fields
+}
+
+var vaultTestKeys = []keyFields{
+	{
+		Issuer: "GitHub", Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Period: 30,
+		Tags: []string{"work"}, Secret: "JBSWY3DPEHPK3PXP",
+	},
+	{
+		Issuer: "Acme", Label: "a@b.c", OTPType: "TOTP", Algorithm: "SHA256", Digits: 8, Period: 60,
+		Tags: []string{"work", "personal"}, Secret: "GEZDGNBVGY3TQOJQ",
+	},
+	{
+		Label: "no issuer", OTPType: "TOTP", Algorithm: "SHA512", Digits: 6, Period: 30,
+		Tags: []string{}, Secret: "MFRGGZDFMZTWQ2LK",
+	},
+	{
+		Issuer: "Bank", Label: "token", OTPType: "HOTP", Algorithm: "SHA1", Digits: 6, Counter: 42,
+		Tags: []string{"personal"}, Secret: "KRSXG5CTMVRXEZLU",
+	},
+	{
+		Issuer: "Steam", Label: "gamer", OTPType: "STEAM", Algorithm: "SHA1", Digits: 5, Period: 30,
+		Tags: []string{}, Secret: "ONSWG4TFOQ",
+	},
+}
+
+func newTestKeys(t *testing.T) []*otp.OTPKey {
+	t.Helper()
+
+	otpKeys := []*otp.OTPKey{}
+
+	for _, fields := range vaultTestKeys {
+		otpKey := &otp.OTPKey{
+			Issuer:       fields.Issuer,
+			Label:        fields.Label,
+			OTPType:      fields.OTPType,
+			AlgorithmStr: fields.Algorithm,
+			DigitsInt:    fields.Digits,
+			Period:       fields.Period,
+			Counter:      fields.Counter,
+			Tags:         fields.Tags,
+			Thumbnail:    "Default",
+		}
+		otpKey.SetSecret([]byte(fields.Secret))
+
+		if err := otpKey.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+
+		otpKeys = append(otpKeys, otpKey)
+	}
+
+	return otpKeys
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	// Kept referenced until the end, memguard destroys unreachable buffers
+	password := memguard.NewBufferFromBytes([]byte("hunter2"))
+	defer password.Destroy()
+
+	wrongPassword := memguard.NewBufferFromBytes([]byte("wrong"))
+	defer wrongPassword.Destroy()
+
+	tests := []struct {
+		name          string
+		password      *memguard.LockedBuffer
+		wantEncrypted bool
+	}{
+		{name: "plaintext", password: nil, wantEncrypted: false},
+		{name: "encrypted", password: password, wantEncrypted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := Encode(newTestKeys(t), tt.password)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			if !IsVault(encoded) {
+				t.Errorf("IsVault() = false, want true")
+			}
+
+			encrypted, err := IsEncrypted(encoded)
+			if err != nil {
+				t.Fatalf("IsEncrypted() error = %v", err)
+			}
+
+			if encrypted != tt.wantEncrypted {
+				t.Errorf("IsEncrypted() = %v, want %v", encrypted, tt.wantEncrypted)
+			}
+
+			decoded, err := Decode(encoded, tt.password)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			got := []keyFields{}
+			for _, otpKey := range decoded {
+				got = append(got, fieldsOf(otpKey))
+			}
+
+			if !reflect.DeepEqual(got, vaultTestKeys) {
+				t.Errorf("round trip = %+v, want %+v", got, vaultTestKeys)
+			}
+
+			if !tt.wantEncrypted {
+				return
+			}
+
+			if _, err := Decode(encoded, wrongPassword); err == nil {
+				t.Errorf("Decode() with a wrong password succeeded, want an error")
+			}
+
+			if _, err := Decode(encoded, nil); err == nil {
+				t.Errorf("Decode() without a password succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestIsVault(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "vault", content: updateTestVault, want: true},
+		{name: "andOTP backup", content: `[{"secret": "JBSWY3DPEHPK3PXP", "type": "TOTP"}]`, want: false},
+		{name: "missing db", content: `{"version": 1, "header": {}}`, want: false},
+		{name: "not JSON", content: "otpauth://totp/me?secret=JBSWY3DPEHPK3PXP", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsVault([]byte(tt.content)); got != tt.want {
+				t.Errorf("IsVault() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
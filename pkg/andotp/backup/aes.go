@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Layout of an andOTP encrypted backup:
+//
+//	[iterations (4 bytes, big-endian)][salt (12 bytes)][IV (12 bytes)][AES-256-GCM ciphertext + tag]
+//
+// The AES key is derived from the password with PBKDF2-HMAC-SHA1.
+const (
+	aesIterationsLen = 4
+	aesSaltLen       = 12
+	aesIVLen         = 12
+	aesKeyLen        = 32
+
+	// Range of PBKDF2 iterations used by andOTP when creating a backup
+	aesMinIterations = 140000
+	aesMaxIterations = 160000
+
+	// Upper bound of PBKDF2 iterations accepted when detecting an andOTP
+	// encrypted backup, anything above is likely not an iteration count
+	aesSniffMaxIterations = 10000000
+)
+
+// encryptAES encrypts an andOTP plaintext JSON backup with the given password,
+// producing the same format as andOTP's encrypted backups
+func encryptAES(plaintext []byte, password []byte) ([]byte, error) {
+	iterationsOffset, err := rand.Int(rand.Reader, big.NewInt(aesMaxIterations-aesMinIterations))
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating PBKDF2 iterations")
+	}
+
+	iterations := aesMinIterations + int(iterationsOffset.Int64())
+
+	header := make([]byte, aesIterationsLen+aesSaltLen+aesIVLen)
+	binary.BigEndian.PutUint32(header[:aesIterationsLen], uint32(iterations))
+
+	salt := header[aesIterationsLen : aesIterationsLen+aesSaltLen]
+	iv := header[aesIterationsLen+aesSaltLen:]
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "error generating salt")
+	}
+
+	if _, err := rand.Read(iv); err != nil {
+		return nil, errors.Wrap(err, "error generating IV")
+	}
+
+	key := pbkdf2.Key(password, salt, iterations, aesKeyLen, sha1.New)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AES cipher")
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AES-GCM cipher")
+	}
+
+	return aesgcm.Seal(header, iv, plaintext, nil), nil
+}
@@ -1,73 +1,177 @@
 package backup
 
 import (
-	"encoding/json"
+	"log"
 
+	"github.com/awnumar/memguard"
 	memguardcore "github.com/awnumar/memguard/core"
-	"github.com/grijul/go-andotp/andotp"
 	"github.com/pkg/errors"
 
 	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
 )
 
-// Backup is a struct for an andOTP backup
+// Backup is a struct for an andOTP backup, or another authenticator's backup
+// in one of the registered formats
 type Backup struct {
 	OTPKeys []*otp.OTPKey
 
+	format    Format
 	encrypted []byte
+
+	// Formats to try if the detected format fails to decode the backup, and
+	// the error of the detected format if it already failed without a password
+	fallbacks []Format
+	formatErr error
+
+	// Content the backup was parsed from, kept for formats implementing
+	// Updater
+	original *memguard.Enclave
 }
 
-func NewBackup(content []byte) (*Backup, error) {
-	backup := &Backup{}
+// NewBackup parses the backup, detecting its format from the content with the
+// given formats. If the detected format fails to decode binary content, the
+// formats' fallbacks are tried before giving up.
+func NewBackup(content []byte, formats Formats) (*Backup, error) {
+	format, err := formats.Detect(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBackup(content, format, formats.Fallbacks(format, content))
+}
+
+// NewBackupWithFormat parses the backup in the given format. Encrypted backups
+// are parsed on Decrypt().
+func NewBackupWithFormat(content []byte, format Format) (*Backup, error) {
+	return newBackup(content, format, nil)
+}
+
+func newBackup(content []byte, format Format, fallbacks []Format) (*Backup, error) {
+	backup := &Backup{format: format, fallbacks: fallbacks}
+
+	if _, ok := format.(Updater); ok && len(content) > 0 {
+		original := make([]byte, len(content))
+		copy(original, content)
+
+		// NewEnclave wipes the copy
+		backup.original = memguard.NewEnclave(original)
+	}
+
+	encrypted, err := format.Encrypted(content)
+	if err != nil {
+		return nil, err
+	}
 
-	if json.Valid(content) {
-		if err := backup.parsePlaintext(content); err != nil {
+	if encrypted {
+		backup.encrypted = content
+		return backup, nil
+	}
+
+	otpKeys, err := format.Decode(content, nil)
+	if err != nil {
+		if len(fallbacks) == 0 {
 			return nil, err
 		}
 
-		memguardcore.Wipe(content)
-	} else {
+		// The fallbacks are encrypted formats, decode them on Decrypt()
 		backup.encrypted = content
+		backup.formatErr = err
+
+		return backup, nil
 	}
 
+	backup.OTPKeys = otpKeys
+	memguardcore.Wipe(content)
+
 	return backup, nil
 }
 
+// Format returns the name of the backup's format
+func (b *Backup) Format() string {
+	return b.format.Name()
+}
+
 // IsEncrypted returns true if the backup is currently encrypted
 func (b *Backup) IsEncrypted() bool {
 	return b.encrypted != nil
 }
 
 // Decrypt decrypts the backup
-func (b *Backup) Decrypt(password string) error {
+func (b *Backup) Decrypt(password *memguard.LockedBuffer) error {
 	if !b.IsEncrypted() {
 		return nil
 	}
 
-	plaintext, err := andotp.Decrypt(b.encrypted, password)
+	formats := append([]Format{b.format}, b.fallbacks...)
+	if b.formatErr != nil {
+		formats = b.fallbacks
+	}
+
+	format, otpKeys, err := DecodeFirst(formats, b.encrypted, password)
 	if err != nil {
-		return errors.Wrap(err, "unable to decrypt andOTP backup file")
+		return err
 	}
 
-	if err := b.parsePlaintext(plaintext); err != nil {
-		return errors.Wrap(err, "error parsing decrypted andOTP backup")
+	if b.formatErr != nil {
+		log.Printf("Decoded the backup as %s after %s failed: %v", format.Name(), b.format.Name(), b.formatErr)
+		b.formatErr = nil
 	}
 
+	b.format = format
+
 	memguardcore.Wipe(b.encrypted)
-	memguardcore.Wipe(plaintext)
 
+	b.OTPKeys = otpKeys
 	b.encrypted = nil
 
 	return nil
 }
 
-func (b *Backup) parsePlaintext(backupContents []byte) error {
-	otpKeys, err := otp.OTPKeysFromJSON(backupContents)
-	if err != nil {
-		return errors.Wrap(err, "error parsing andOTP plaintext JSON backup")
+// Plaintext serializes the backup's OTP keys into andOTP plaintext JSON. The
+// backup must be decrypted first.
+func (b *Backup) Plaintext() ([]byte, error) {
+	if b.IsEncrypted() {
+		return nil, errors.New("backup is still encrypted")
 	}
 
-	b.OTPKeys = otpKeys
+	return andOTPJSONFormat{}.Encode(b.OTPKeys, nil)
+}
 
-	return nil
+// Encrypt serializes the backup's OTP keys and encrypts them with the given
+// password into an andOTP encrypted backup (.json.aes), which can be imported
+// back into andOTP. The backup must be decrypted first.
+func (b *Backup) Encrypt(password *memguard.LockedBuffer) ([]byte, error) {
+	if b.IsEncrypted() {
+		return nil, errors.New("backup is still encrypted")
+	}
+
+	return andOTPAESFormat{}.Encode(b.OTPKeys, password)
+}
+
+// Serialize serializes the backup's OTP keys in the format the backup was
+// parsed from, encrypted with the given password if it's non-nil. Formats
+// implementing Updater update the content the backup was parsed from instead.
+// Backups in read-only formats can't be serialized.
+func (b *Backup) Serialize(password *memguard.LockedBuffer) ([]byte, error) {
+	if b.IsEncrypted() {
+		return nil, errors.New("backup is still encrypted")
+	}
+
+	if updater, ok := b.format.(Updater); ok && b.original != nil {
+		originalBuf, err := b.original.Open()
+		if err != nil {
+			memguard.SafePanic(err)
+		}
+
+		defer originalBuf.Destroy()
+
+		return updater.Update(originalBuf.Bytes(), b.OTPKeys, password)
+	}
+
+	content, err := b.format.Encode(b.OTPKeys, password)
+	if errors.Is(err, ErrEncodeUnsupported) {
+		return nil, errors.Errorf("%s backups can't be written back", b.format.Name())
+	}
+
+	return content, err
 }
@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// ErrEncodeUnsupported is returned by Format.Encode for read-only formats
+var ErrEncodeUnsupported = errors.New("format does not support writing")
+
+// Format is a backup file format that can be detected from the file contents
+type Format interface {
+	// Name returns the unique name of the format, used to select it explicitly
+	Name() string
+
+	// Sniff returns true if the content looks like this format. Formats that
+	// can't be told apart from others (e.g. random-looking ciphertext) may
+	// always return false, they can still be selected by name.
+	Sniff(content []byte) bool
+
+	// Encrypted returns true if the content needs a password to be decoded
+	Encrypted(content []byte) (bool, error)
+
+	// Decode parses the OTP keys from the content. The password is nil if the
+	// content isn't encrypted.
+	Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error)
+
+	// Encode serializes the OTP keys, encrypted with the password if it's
+	// non-nil. Returns ErrEncodeUnsupported for read-only formats.
+	Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error)
+}
+
+// Updater is an optional capability of a Format for writing a backup back by
+// updating the content it was decoded from, preserving what the OTP keys don't
+// hold, e.g. another authenticator's icons or encryption slots
+type Updater interface {
+	// Update serializes the OTP keys into the original content. The password
+	// is the one the original content was decrypted with, nil if it wasn't
+	// encrypted.
+	Update(original []byte, otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error)
+}
+
+// Formats is an ordered list of backup formats, in sniffing order
+type Formats []Format
+
+// DefaultFormats returns the backup formats of this package, in sniffing
+// order. More specific formats come first, OpenPGP only after andOTP AES so
+// that binary content is tried as andOTP's own format first, and legacy
+// andOTP AES is the catch-all for content nothing else recognizes.
+func DefaultFormats() Formats {
+	return Formats{
+		aegisFormat{},
+		twoFASFormat{},
+		otpAuthFormat{},
+		andOTPJSONFormat{},
+		andOTPAESFormat{},
+		&PGPFormat{},
+		andOTPLegacyAESFormat{},
+	}
+}
+
+// With returns a copy of the formats with the format replacing the format
+// with the same name, keeping its sniffing order, or sniffed before the others
+// if there's none
+func (f Formats) With(format Format) Formats {
+	formats := make(Formats, 0, len(f)+1)
+	replaced := false
+
+	for _, existing := range f {
+		if existing.Name() == format.Name() {
+			formats = append(formats, format)
+			replaced = true
+		} else {
+			formats = append(formats, existing)
+		}
+	}
+
+	if !replaced {
+		formats = append(Formats{format}, formats...)
+	}
+
+	return formats
+}
+
+// Names returns the names of the formats
+func (f Formats) Names() []string {
+	names := make([]string, 0, len(f))
+	for _, format := range f {
+		names = append(names, format.Name())
+	}
+
+	return names
+}
+
+// ByName returns the format with the given name
+func (f Formats) ByName(name string) (Format, error) {
+	for _, format := range f {
+		if format.Name() == name {
+			return format, nil
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"unsupported backup format '%s', supported formats: %s",
+		name, strings.Join(f.Names(), ", "),
+	)
+}
+
+// Detect returns the first format that recognizes the content
+func (f Formats) Detect(content []byte) (Format, error) {
+	for _, format := range f {
+		if format.Sniff(content) {
+			return format, nil
+		}
+	}
+
+	return nil, errors.New("unable to detect the backup format")
+}
+
+// Fallbacks returns the formats to try when the detected format fails to
+// decode binary content: andOTP AES, then legacy andOTP AES, if they recognize
+// the content. Backups of older andOTP versions are random-looking, so they
+// may be detected as another binary format, e.g. when their IV looks like an
+// iteration count or an OpenPGP packet header.
+func (f Formats) Fallbacks(detected Format, content []byte) []Format {
+	if !isBinary(content) {
+		return nil
+	}
+
+	fallbacks := []Format{}
+
+	for _, name := range []string{FormatAndOTPAES, FormatAndOTPLegacyAES} {
+		if name == detected.Name() {
+			continue
+		}
+
+		format, err := f.ByName(name)
+		if err == nil && format.Sniff(content) {
+			fallbacks = append(fallbacks, format)
+		}
+	}
+
+	return fallbacks
+}
+
+// DecodeFirst decodes the content with the first of the formats able to, and
+// returns that format. If none is, the error of the first format is returned.
+func DecodeFirst(formats []Format, content []byte, password *memguard.LockedBuffer) (Format, []*otp.OTPKey, error) {
+	var firstErr error
+
+	for idx, format := range formats {
+		otpKeys, err := format.Decode(content, password)
+		if err == nil {
+			if idx > 0 {
+				log.Printf(
+					"Decoded the backup as %s after %s failed: %v",
+					format.Name(), formats[0].Name(), firstErr,
+				)
+			}
+
+			return format, otpKeys, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, nil, firstErr
+}
+
+// isBinary returns true if the content isn't text
+func isBinary(content []byte) bool {
+	return !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0
+}
+
+// AvailableFormats returns the names of the default backup formats
+func AvailableFormats() []string {
+	return DefaultFormats().Names()
+}
+
+// FormatByName returns the default backup format with the given name
+func FormatByName(name string) (Format, error) {
+	return DefaultFormats().ByName(name)
+}
+
+// DetectFormat returns the first default backup format that recognizes the
+// content
+func DetectFormat(content []byte) (Format, error) {
+	return DefaultFormats().Detect(content)
+}
@@ -0,0 +1,190 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/grijul/go-andotp/andotp"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// Names of the andOTP backup formats
+const (
+	FormatAndOTPJSON      = "andotp-json"
+	FormatAndOTPAES       = "andotp-aes"
+	FormatAndOTPLegacyAES = "andotp-aes-legacy"
+)
+
+// Size of the IV prepended to legacy andOTP encrypted backups
+const legacyIVLen = 12
+
+// andOTPJSONFormat is andOTP's plaintext backup (otp_accounts.json)
+type andOTPJSONFormat struct{}
+
+func (andOTPJSONFormat) Name() string {
+	return FormatAndOTPJSON
+}
+
+func (andOTPJSONFormat) Sniff(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) && json.Valid(content)
+}
+
+func (andOTPJSONFormat) Encrypted(content []byte) (bool, error) {
+	return false, nil
+}
+
+func (andOTPJSONFormat) Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	otpKeys, err := otp.OTPKeysFromJSON(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing andOTP plaintext JSON backup")
+	}
+
+	return otpKeys, nil
+}
+
+func (andOTPJSONFormat) Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	plaintext, err := otp.OTPKeysToJSON(otpKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing andOTP plaintext JSON backup")
+	}
+
+	return plaintext, nil
+}
+
+// andOTPAESFormat is andOTP's password-encrypted backup (otp_accounts.json.aes)
+// with a PBKDF2 derived key
+type andOTPAESFormat struct{}
+
+func (andOTPAESFormat) Name() string {
+	return FormatAndOTPAES
+}
+
+// Sniff accepts anything long enough to hold the header and the GCM tag that
+// starts with a plausible PBKDF2 iteration count, the rest can't be told apart
+// from random data
+func (andOTPAESFormat) Sniff(content []byte) bool {
+	if len(content) <= aesIterationsLen+aesSaltLen+aesIVLen+16 {
+		return false
+	}
+
+	iterations := binary.BigEndian.Uint32(content[:aesIterationsLen])
+
+	return iterations > 0 && iterations <= aesSniffMaxIterations
+}
+
+func (andOTPAESFormat) Encrypted(content []byte) (bool, error) {
+	return true, nil
+}
+
+func (andOTPAESFormat) Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	if password == nil {
+		return nil, errors.New("andOTP backup is encrypted but no password was given")
+	}
+
+	if len(content) <= aesIterationsLen+aesSaltLen+aesIVLen+16 {
+		return nil, errors.New("andOTP backup file is too short")
+	}
+
+	plaintext, err := andotp.Decrypt(content, password.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt andOTP backup file")
+	}
+
+	defer memguardcore.Wipe(plaintext)
+
+	return andOTPJSONFormat{}.Decode(plaintext, nil)
+}
+
+func (andOTPAESFormat) Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	if password == nil {
+		return nil, errors.New("andOTP encrypted backup needs a password")
+	}
+
+	plaintext, err := andOTPJSONFormat{}.Encode(otpKeys, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer memguardcore.Wipe(plaintext)
+
+	encrypted, err := encryptAES(plaintext, password.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encrypt andOTP backup")
+	}
+
+	return encrypted, nil
+}
+
+// andOTPLegacyAESFormat is the encrypted backup of older andOTP versions,
+// which used the SHA-256 hash of the password as the AES key. It starts with a
+// random IV instead of an iteration count, so it's the catch-all for content
+// no other format recognizes. It's written back in the current
+// andOTPAESFormat since andOTP can't import the legacy format anymore.
+type andOTPLegacyAESFormat struct{}
+
+func (andOTPLegacyAESFormat) Name() string {
+	return FormatAndOTPLegacyAES
+}
+
+func (andOTPLegacyAESFormat) Sniff(content []byte) bool {
+	return len(content) > legacyIVLen+16
+}
+
+func (andOTPLegacyAESFormat) Encrypted(content []byte) (bool, error) {
+	return true, nil
+}
+
+func (andOTPLegacyAESFormat) Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	if password == nil {
+		return nil, errors.New("andOTP backup is encrypted but no password was given")
+	}
+
+	plaintext, err := decryptLegacyAES(content, password.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt legacy andOTP backup file")
+	}
+
+	defer memguardcore.Wipe(plaintext)
+
+	return andOTPJSONFormat{}.Decode(plaintext, nil)
+}
+
+func (andOTPLegacyAESFormat) Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	return andOTPAESFormat{}.Encode(otpKeys, password)
+}
+
+// decryptLegacyAES decrypts a legacy andOTP backup: a 12-byte IV followed by
+// the AES-GCM ciphertext, keyed with the SHA-256 hash of the password
+func decryptLegacyAES(content, password []byte) ([]byte, error) {
+	if len(content) <= legacyIVLen {
+		return nil, errors.New("backup is too short")
+	}
+
+	key := sha256.Sum256(password)
+	defer memguardcore.Wipe(key[:])
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AES cipher")
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AES-GCM cipher")
+	}
+
+	plaintext, err := aesgcm.Open(nil, content[:legacyIVLen], content[legacyIVLen:], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "wrong password or corrupted backup")
+	}
+
+	return plaintext, nil
+}
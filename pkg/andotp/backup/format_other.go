@@ -0,0 +1,197 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/aegis"
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+	"github.com/putrasattvika/andotp-cli/pkg/googleauth"
+	"github.com/putrasattvika/andotp-cli/pkg/twofas"
+)
+
+// Names of the other authenticators' backup formats
+const (
+	FormatAegis   = "aegis"
+	Format2FAS    = "2fas"
+	FormatOTPAuth = "otpauth"
+)
+
+// aegisFormat is an Aegis JSON vault, plaintext or encrypted
+type aegisFormat struct{}
+
+func (aegisFormat) Name() string {
+	return FormatAegis
+}
+
+func (aegisFormat) Sniff(content []byte) bool {
+	return aegis.IsVault(content)
+}
+
+func (aegisFormat) Encrypted(content []byte) (bool, error) {
+	return aegis.IsEncrypted(content)
+}
+
+func (aegisFormat) Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	otpKeys, err := aegis.Decode(content, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode Aegis vault")
+	}
+
+	return otpKeys, nil
+}
+
+// Encode writes a new vault, encrypted with a single password slot if password
+// is non-nil
+func (aegisFormat) Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	vault, err := aegis.Encode(otpKeys, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to serialize Aegis vault")
+	}
+
+	return vault, nil
+}
+
+// Update writes the OTP keys back into the original vault, keeping its slots,
+// master key and the entries' fields andOTP doesn't know about
+func (aegisFormat) Update(original []byte, otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	vault, err := aegis.Update(original, otpKeys, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to update Aegis vault")
+	}
+
+	return vault, nil
+}
+
+// twoFASFormat is a 2FAS Authenticator backup, plaintext or encrypted
+type twoFASFormat struct{}
+
+func (twoFASFormat) Name() string {
+	return Format2FAS
+}
+
+func (twoFASFormat) Sniff(content []byte) bool {
+	return twofas.IsBackup(content)
+}
+
+func (twoFASFormat) Encrypted(content []byte) (bool, error) {
+	return twofas.IsEncrypted(content)
+}
+
+func (twoFASFormat) Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	otpKeys, err := twofas.Decode(content, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode 2FAS backup")
+	}
+
+	return otpKeys, nil
+}
+
+func (twoFASFormat) Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	return nil, ErrEncodeUnsupported
+}
+
+// otpAuthFormat is a list of otpauth:// and Google Authenticator's
+// otpauth-migration:// URIs, one per line. Empty lines and lines starting with
+// '#' are ignored.
+type otpAuthFormat struct{}
+
+func (otpAuthFormat) Name() string {
+	return FormatOTPAuth
+}
+
+func (otpAuthFormat) Sniff(content []byte) bool {
+	lines, err := readLines(content)
+	if err != nil || len(lines) == 0 {
+		return false
+	}
+
+	return strings.HasPrefix(lines[0], "otpauth://") || strings.HasPrefix(lines[0], "otpauth-migration://")
+}
+
+func (otpAuthFormat) Encrypted(content []byte) (bool, error) {
+	return false, nil
+}
+
+func (otpAuthFormat) Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	lines, err := readLines(content)
+	if err != nil {
+		return nil, err
+	}
+
+	otpKeys := []*otp.OTPKey{}
+	migrationURIs := []string{}
+
+	for idx, line := range lines {
+		if strings.HasPrefix(line, "otpauth-migration://") {
+			migrationURIs = append(migrationURIs, line)
+			continue
+		}
+
+		otpKey, err := otp.OTPKeyFromURI(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "URI #%d", idx+1)
+		}
+
+		otpKeys = append(otpKeys, otpKey)
+	}
+
+	if len(migrationURIs) > 0 {
+		migrated, err := googleauth.OTPKeysFromMigrationURIs(migrationURIs)
+		if err != nil {
+			return nil, err
+		}
+
+		otpKeys = append(otpKeys, migrated...)
+	}
+
+	return otpKeys, nil
+}
+
+func (otpAuthFormat) Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	if password != nil {
+		return nil, errors.New("otpauth URI lists can't be encrypted")
+	}
+
+	var buf bytes.Buffer
+
+	for _, otpKey := range otpKeys {
+		uri, err := otpKey.URI()
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert OTP key '%s:%s' to URI", otpKey.Issuer, otpKey.Label)
+		}
+
+		buf.WriteString(uri)
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readLines returns the non-empty lines of the content. Lines starting with
+// '#' are ignored.
+func readLines(content []byte) ([]string, error) {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,256 @@
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// FormatAndOTPPGP is the name of andOTP's OpenPGP encrypted backup format
+const FormatAndOTPPGP = "andotp-pgp"
+
+// Prefix of ASCII armored OpenPGP data
+const pgpArmorPrefix = "-----BEGIN PGP"
+
+// PGPFormat is andOTP's OpenPGP encrypted backup (otp_accounts.json.gpg).
+//
+// With a private key ring, backups are decrypted with it, unlocking the
+// private keys with the backup password if they're passphrase-protected, and
+// written back encrypted to the key ring's first key. Otherwise, backups are
+// decrypted by running gpg --decrypt, so the gpg binary must be installed:
+// gpg-agent doesn't parse OpenPGP messages itself, gpg gets the private key
+// and its passphrase from the user's GnuPG keyring and gpg-agent. Such backups
+// are read-only.
+type PGPFormat struct {
+	keyRing openpgp.EntityList
+}
+
+// NewPGPFormat creates a PGPFormat with the armored or binary private key
+// ring at the given path
+func NewPGPFormat(privateKeyPath string) (*PGPFormat, error) {
+	content, err := ioutil.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read OpenPGP private key")
+	}
+
+	var keyRing openpgp.EntityList
+
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte(pgpArmorPrefix)) {
+		keyRing, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+	} else {
+		keyRing, err = openpgp.ReadKeyRing(bytes.NewReader(content))
+	}
+
+	memguardcore.Wipe(content)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid OpenPGP private key")
+	}
+
+	if len(keyRing.DecryptionKeys()) == 0 {
+		return nil, errors.New("OpenPGP key has no private decryption key")
+	}
+
+	return &PGPFormat{keyRing: keyRing}, nil
+}
+
+func (f *PGPFormat) Name() string {
+	return FormatAndOTPPGP
+}
+
+// Sniff accepts ASCII armored messages, and binary messages whose first packet
+// parses as a public-key or symmetric-key encrypted session key. Checking the
+// packet's tag alone would also accept a few percent of andOTP AES backups,
+// which are random-looking.
+func (f *PGPFormat) Sniff(content []byte) bool {
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte(pgpArmorPrefix+" MESSAGE-----")) {
+		return true
+	}
+
+	if len(content) == 0 || content[0]&0x80 == 0 {
+		return false
+	}
+
+	p, err := packet.Read(bytes.NewReader(content))
+	if err != nil {
+		return false
+	}
+
+	switch p.(type) {
+	case *packet.EncryptedKey, *packet.SymmetricKeyEncrypted:
+		return true
+	default:
+		return false
+	}
+}
+
+// Encrypted returns true if the private keys need a passphrase. With the gpg
+// binary, gpg-agent asks for the passphrase by itself.
+func (f *PGPFormat) Encrypted(content []byte) (bool, error) {
+	for _, key := range f.keyRing.DecryptionKeys() {
+		if key.PrivateKey.Encrypted {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (f *PGPFormat) Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	var plaintextBuf *memguard.LockedBuffer
+	var err error
+
+	if f.keyRing == nil {
+		plaintextBuf, err = decryptPGPWithGPGBinary(content)
+	} else {
+		plaintextBuf, err = f.decryptPGPWithKeyRing(content, password)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt OpenPGP andOTP backup file")
+	}
+
+	defer plaintextBuf.Destroy()
+
+	return andOTPJSONFormat{}.Decode(plaintextBuf.Bytes(), nil)
+}
+
+// Encode encrypts the backup to the key ring's first key, the password is
+// unused. Without a key ring, backups can't be written back since the
+// recipient is unknown.
+func (f *PGPFormat) Encode(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	if f.keyRing == nil {
+		return nil, errors.New("OpenPGP backups can only be written back with a private key file")
+	}
+
+	plaintext, err := andOTPJSONFormat{}.Encode(otpKeys, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer memguardcore.Wipe(plaintext)
+
+	var buf bytes.Buffer
+
+	armored, err := armor.Encode(&buf, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating OpenPGP armor")
+	}
+
+	w, err := openpgp.Encrypt(armored, f.keyRing[:1], nil, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encrypt OpenPGP andOTP backup")
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, errors.Wrap(err, "unable to encrypt OpenPGP andOTP backup")
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to encrypt OpenPGP andOTP backup")
+	}
+
+	if err := armored.Close(); err != nil {
+		return nil, errors.Wrap(err, "error closing OpenPGP armor")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decryptPGPWithKeyRing decrypts the message with the key ring, unlocking the
+// private keys with the password first. The plaintext is returned inside a
+// memguard buffer which must be destroyed by the caller.
+func (f *PGPFormat) decryptPGPWithKeyRing(content []byte, password *memguard.LockedBuffer) (*memguard.LockedBuffer, error) {
+	for _, key := range f.keyRing.DecryptionKeys() {
+		if !key.PrivateKey.Encrypted {
+			continue
+		}
+
+		if password == nil {
+			return nil, errors.New("OpenPGP private key is encrypted but no password was given")
+		}
+
+		if err := key.PrivateKey.Decrypt(password.Bytes()); err != nil {
+			return nil, errors.Wrap(err, "unable to unlock OpenPGP private key, wrong password?")
+		}
+	}
+
+	message := &bytes.Buffer{}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte(pgpArmorPrefix)) {
+		block, err := armor.Decode(bytes.NewReader(content))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid OpenPGP armor")
+		}
+
+		if _, err := message.ReadFrom(block.Body); err != nil {
+			return nil, errors.Wrap(err, "invalid OpenPGP armor")
+		}
+	} else {
+		message.Write(content)
+	}
+
+	md, err := openpgp.ReadMessage(message, f.keyRing, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read into locked memory, buffers outgrown meanwhile are wiped
+	plaintextBuf, err := memguard.NewBufferFromEntireReader(md.UnverifiedBody)
+	if err != nil {
+		plaintextBuf.Destroy()
+		return nil, err
+	}
+
+	return plaintextBuf, nil
+}
+
+// decryptPGPWithGPGBinary decrypts the message by running gpg --decrypt, which
+// asks gpg-agent for the private key. The plaintext is returned inside a
+// memguard buffer which must be destroyed by the caller.
+func decryptPGPWithGPGBinary(content []byte) (*memguard.LockedBuffer, error) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return nil, errors.Wrap(err, "gpg is required to decrypt OpenPGP backups without a private key file")
+	}
+
+	cmd := exec.Command("gpg", "--quiet", "--decrypt")
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating gpg stdout pipe")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "error starting gpg")
+	}
+
+	// Read into locked memory, buffers outgrown meanwhile are wiped
+	plaintextBuf, err := memguard.NewBufferFromEntireReader(stdout)
+	if err != nil {
+		plaintextBuf.Destroy()
+
+		// gpg may be blocked writing the rest of its output
+		cmd.Process.Kill()
+		cmd.Wait()
+
+		return nil, errors.Wrap(err, "error reading gpg output")
+	}
+
+	if err := cmd.Wait(); err != nil {
+		plaintextBuf.Destroy()
+		return nil, errors.Wrap(err, "gpg --decrypt failed")
+	}
+
+	return plaintextBuf, nil
+}
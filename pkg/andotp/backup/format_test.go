@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/awnumar/memguard"
+)
+
+const fallbackTestBackup = `[{"secret": "JBSWY3DPEHPK3PXP", "issuer": "GitHub", "label": "me", "digits": 6,
+"type": "TOTP", "algorithm": "SHA1", "last_used": 0, "used_frequency": 0, "period": 30, "tags": []}]`
+
+// encryptLegacyAES encrypts a plaintext backup like older andOTP versions
+func encryptLegacyAES(t *testing.T, iv []byte, plaintext []byte, password []byte) []byte {
+	t.Helper()
+
+	key := sha256.Sum256(password)
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatalf("aes.NewCipher() error = %v", err)
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("cipher.NewGCM() error = %v", err)
+	}
+
+	return aesgcm.Seal(append([]byte{}, iv...), iv, plaintext, nil)
+}
+
+// testFormats returns the default formats with OpenPGP backups decrypted with
+// a new unencrypted key instead of the gpg binary
+func testFormats(t *testing.T) Formats {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("andotp-cli test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("openpgp.NewEntity() error = %v", err)
+	}
+
+	return DefaultFormats().With(&PGPFormat{keyRing: openpgp.EntityList{entity}})
+}
+
+// encryptPGP encrypts a plaintext backup into a binary OpenPGP message to the
+// formats' OpenPGP key
+func encryptPGP(t *testing.T, formats Formats, plaintext []byte) []byte {
+	t.Helper()
+
+	format, err := formats.ByName(FormatAndOTPPGP)
+	if err != nil {
+		t.Fatalf("ByName() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	w, err := openpgp.Encrypt(&buf, format.(*PGPFormat).keyRing, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("openpgp.Encrypt() error = %v", err)
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("openpgp.Encrypt() error = %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("openpgp.Encrypt() error = %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestNewBackupFallbacks(t *testing.T) {
+	// Kept referenced until the end, memguard destroys unreachable buffers
+	password := memguard.NewBufferFromBytes([]byte("hunter2"))
+	defer password.Destroy()
+
+	formats := testFormats(t)
+
+	tests := []struct {
+		name           string
+		iv             []byte
+		wantDetected   string
+		wantDecodedAs  string
+		wantFallbacks  int
+		wrongPassword  bool
+		wantDecryptErr bool
+	}{
+		{
+			name:          "IV that looks like an iteration count",
+			iv:            []byte{0x00, 0x02, 0x00, 0x00, 1, 2, 3, 4, 5, 6, 7, 8},
+			wantDetected:  FormatAndOTPAES,
+			wantDecodedAs: FormatAndOTPLegacyAES,
+			wantFallbacks: 1,
+		},
+		{
+			// New format public-key encrypted session key packet, version 3,
+			// 8-byte key ID, unknown public-key algorithm
+			name:          "IV that looks like an OpenPGP packet",
+			iv:            []byte{0xc1, 0x0a, 0x03, 1, 2, 3, 4, 5, 6, 7, 8, 0x63},
+			wantDetected:  FormatAndOTPPGP,
+			wantDecodedAs: FormatAndOTPLegacyAES,
+			wantFallbacks: 1,
+		},
+		{
+			name:          "random IV",
+			iv:            []byte{0xf3, 0x17, 0x5a, 0x9e, 1, 2, 3, 4, 5, 6, 7, 8},
+			wantDetected:  FormatAndOTPLegacyAES,
+			wantDecodedAs: FormatAndOTPLegacyAES,
+			wantFallbacks: 0,
+		},
+		{
+			name:           "wrong password",
+			iv:             []byte{0xc1, 0x0a, 0x03, 1, 2, 3, 4, 5, 6, 7, 8, 0x63},
+			wantDetected:   FormatAndOTPPGP,
+			wantFallbacks:  1,
+			wrongPassword:  true,
+			wantDecryptErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encryptionPassword := []byte("hunter2")
+			if tt.wrongPassword {
+				encryptionPassword = []byte("wrong")
+			}
+
+			content := encryptLegacyAES(t, tt.iv, []byte(fallbackTestBackup), encryptionPassword)
+
+			detected, err := formats.Detect(content)
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+
+			if detected.Name() != tt.wantDetected {
+				t.Errorf("Detect() = %s, want %s", detected.Name(), tt.wantDetected)
+			}
+
+			if got := len(formats.Fallbacks(detected, content)); got != tt.wantFallbacks {
+				t.Errorf("len(Fallbacks()) = %d, want %d", got, tt.wantFallbacks)
+			}
+
+			backup, err := NewBackup(content, formats)
+			if err != nil {
+				t.Fatalf("NewBackup() error = %v", err)
+			}
+
+			err = backup.Decrypt(password)
+			if tt.wantDecryptErr {
+				if err == nil {
+					t.Errorf("Decrypt() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+
+			if backup.Format() != tt.wantDecodedAs {
+				t.Errorf("Format() = %s, want %s", backup.Format(), tt.wantDecodedAs)
+			}
+
+			if len(backup.OTPKeys) != 1 || backup.OTPKeys[0].Issuer != "GitHub" {
+				t.Errorf("OTPKeys = %+v, want the GitHub key", backup.OTPKeys)
+			}
+		})
+	}
+}
+
+func TestNewBackupPGPWithoutPassword(t *testing.T) {
+	formats := testFormats(t)
+	content := encryptPGP(t, formats, []byte(fallbackTestBackup))
+
+	detected, err := formats.Detect(content)
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+
+	if detected.Name() != FormatAndOTPPGP {
+		t.Fatalf("Detect() = %s, want %s", detected.Name(), FormatAndOTPPGP)
+	}
+
+	// Legacy andOTP AES recognizes any binary content
+	if len(formats.Fallbacks(detected, content)) == 0 {
+		t.Fatalf("Fallbacks() = none, want legacy andOTP AES")
+	}
+
+	backup, err := NewBackup(content, formats)
+	if err != nil {
+		t.Fatalf("NewBackup() error = %v", err)
+	}
+
+	// The loader only reads a password for encrypted backups
+	if backup.IsEncrypted() {
+		t.Fatalf("IsEncrypted() = true, want false for an unencrypted OpenPGP key")
+	}
+
+	if backup.Format() != FormatAndOTPPGP {
+		t.Errorf("Format() = %s, want %s", backup.Format(), FormatAndOTPPGP)
+	}
+
+	if len(backup.OTPKeys) != 1 || backup.OTPKeys[0].Issuer != "GitHub" {
+		t.Errorf("OTPKeys = %+v, want the GitHub key", backup.OTPKeys)
+	}
+}
+
+func TestDecodePGPWithGPGBinary(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	// Longer than a page, the plaintext buffer grows while reading
+	key := fallbackTestBackup[1 : len(fallbackTestBackup)-1]
+	plaintext := "[" + strings.Repeat(key+",", 49) + key + "]"
+
+	tests := []struct {
+		name     string
+		script   string
+		wantKeys int
+		wantErr  string
+	}{
+		{
+			name:     "decrypted",
+			script:   "cat >/dev/null\ncat \"$PLAINTEXT\"\n",
+			wantKeys: 50,
+		},
+		{
+			name:    "gpg fails",
+			script:  "cat >/dev/null\nexit 2\n",
+			wantErr: "gpg --decrypt failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			plaintextPath := filepath.Join(dir, "otp_accounts.json")
+			if err := ioutil.WriteFile(plaintextPath, []byte(plaintext), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			script := "#!/bin/sh\nPLAINTEXT='" + plaintextPath + "'\n" + tt.script
+			if err := ioutil.WriteFile(filepath.Join(dir, "gpg"), []byte(script), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			oldPath := os.Getenv("PATH")
+			os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+			defer os.Setenv("PATH", oldPath)
+
+			otpKeys, err := (&PGPFormat{}).Decode([]byte("encrypted"), nil)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Decode() error = %v, want an error containing %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if len(otpKeys) != tt.wantKeys {
+				t.Errorf("Decode() returned %d keys, want %d", len(otpKeys), tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestFallbacksOfText(t *testing.T) {
+	content := []byte(fallbackTestBackup)
+
+	if fallbacks := DefaultFormats().Fallbacks(andOTPJSONFormat{}, content); len(fallbacks) != 0 {
+		t.Errorf("Fallbacks() of text content = %v, want none", fallbacks)
+	}
+}
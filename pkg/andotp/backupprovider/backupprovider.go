@@ -13,6 +13,8 @@ var (
 		"file": ConstructLocalFileProvider,
 
 		"kdeconnect": ConstructKDEConnectProvider,
+
+		"sftp": ConstructSFTPProvider,
 	}
 )
 
@@ -28,6 +30,13 @@ func ConstructBackupProvider(uri *url.URL) (BackupProvider, error) {
 	return nil, fmt.Errorf("unsupported backup file URI scheme '%s'", uri.Scheme)
 }
 
+// SupportsWrite returns true if the backup provider can write the backup back,
+// i.e. implements BackupWriter
+func SupportsWrite(provider BackupProvider) bool {
+	_, ok := provider.(BackupWriter)
+	return ok
+}
+
 // ConstructLocalFileProvider constructs a local file backup provider
 func ConstructLocalFileProvider(uri *url.URL) (BackupProvider, error) {
 	return NewLocalFile(uri.Path)
@@ -35,9 +44,11 @@ func ConstructLocalFileProvider(uri *url.URL) (BackupProvider, error) {
 
 // ConstructKDEConnectProvider constructs a KDE Connect backup provider
 func ConstructKDEConnectProvider(uri *url.URL) (BackupProvider, error) {
-	// Manually-defined IP:port of the KDE Connect device
+	// Manually-defined IP:port of the KDE Connect device, optionally with the
+	// device's ID (e.g. ?device=82c27bf0c8d7fbc5) to verify its certificate
 	if len(uri.Port()) > 0 {
 		return NewKDEConnectFromDeviceHostPort(
+			uri.Query().Get("device"),
 			uri.Hostname(),
 			uri.Port(),
 			uri.Path,
@@ -52,3 +63,8 @@ func ConstructKDEConnectProvider(uri *url.URL) (BackupProvider, error) {
 
 	return NewKDEConnect(uriPathSplit[1], "/"+uriPathSplit[2])
 }
+
+// ConstructSFTPProvider constructs an SFTP backup provider
+func ConstructSFTPProvider(uri *url.URL) (BackupProvider, error) {
+	return NewSFTPFromURI(uri)
+}
@@ -11,3 +11,12 @@ type BackupProvider interface {
 	// be encrypted.
 	FetchBackup() ([]byte, error)
 }
+
+// BackupWriter is an optional capability of a BackupProvider for writing a
+// modified backup back to where it was fetched from
+type BackupWriter interface {
+	// StoreBackup atomically replaces the content of the backup file, i.e. the
+	// backup file either has its old or new content even if writing fails
+	// halfway.
+	StoreBackup(backup []byte) error
+}
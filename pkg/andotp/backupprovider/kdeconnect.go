@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 
 	"github.com/pkg/errors"
@@ -19,23 +20,31 @@ var (
 )
 
 // KDEConnect provides andOTP backup from a file inside a KDE Connect device.
-// Implements BackupProvider.
+// Implements BackupProvider and BackupWriter.
+//
+// The device's host key is verified against the certificate KDE Connect stored
+// when pairing, or pinned on first use if there's none.
 type KDEConnect struct {
+	deviceID   string
 	deviceHost string
 	devicePort string
 	filepath   string
 }
 
-// NewKDEConnectFromDeviceHostPort creates a new KDEConnect backup provider from
-// the device's name or ID. The device's SFTP host & port will be discovered
-// automatically.
+// NewKDEConnect creates a new KDEConnect backup provider from the device's name
+// or ID. The device's SFTP host & port will be discovered automatically from
+// kdeconnectd over D-Bus.
 //
-// For the device to expose the SFTP port, we'll need to manually click
-// "Browse this device" on the KDE Connect desktop program. The device's IP can
-// be obtained from `mount | grep kdeconnect | grep <deviceID>`, while its IP can be obtained from
-// `ss -plnaut | grep "<device-ip>" | grep "ssh"`.
+// The device's filesystem is mounted if it's not yet, like the "Browse this
+// device" button of the KDE Connect desktop program does, for the device to
+// expose its SFTP port.
 func NewKDEConnect(deviceNameOrID string, filepath string) (*KDEConnect, error) {
-	devices, err := kdeconnect.ListAvailableDevices()
+	client, err := kdeconnect.NewSessionClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "error connecting to KDE Connect")
+	}
+
+	devices, err := client.ListAvailableDevices()
 	if err != nil {
 		return nil, errors.Wrap(err, "error listing KDE Connect devices")
 	}
@@ -58,12 +67,17 @@ func NewKDEConnect(deviceNameOrID string, filepath string) (*KDEConnect, error)
 	}
 
 	if matchingDevice.SFTPHost == "" || matchingDevice.SFTPPort == "" {
-		return nil, fmt.Errorf(
-			"KDE Connect device with name/id '%s' does not expose its SFTP port. "+
-				"Please manually click the 'Browse this device' button on the KDE Connect "+
-				"desktop program or system tray icon.",
-			deviceNameOrID,
-		)
+		log.Printf("Mounting the filesystem of KDE Connect device '%s'", matchingDevice.Name)
+
+		if err := client.Mount(matchingDevice); err != nil {
+			return nil, errors.Wrapf(
+				err,
+				"KDE Connect device with name/id '%s' does not expose its SFTP port. "+
+					"Please manually click the 'Browse this device' button on the KDE Connect "+
+					"desktop program or system tray icon",
+				deviceNameOrID,
+			)
+		}
 	}
 
 	log.Printf(
@@ -72,67 +86,129 @@ func NewKDEConnect(deviceNameOrID string, filepath string) (*KDEConnect, error)
 		matchingDevice.SFTPHost, matchingDevice.SFTPPort,
 	)
 
-	return NewKDEConnectFromDeviceHostPort(matchingDevice.SFTPHost, matchingDevice.SFTPPort, filepath)
+	return NewKDEConnectFromDeviceHostPort(
+		matchingDevice.ID, matchingDevice.SFTPHost, matchingDevice.SFTPPort, filepath,
+	)
 }
 
 // NewKDEConnectFromDeviceHostPort creates a new KDEConnect backup provider from
-// the device's SFTP host & port.
-func NewKDEConnectFromDeviceHostPort(host string, port string, filepath string) (*KDEConnect, error) {
+// the device's SFTP host & port. The device ID, if known, selects the paired
+// certificate to verify the device against.
+func NewKDEConnectFromDeviceHostPort(deviceID string, host string, port string, filepath string) (*KDEConnect, error) {
 	return &KDEConnect{
+		deviceID:   deviceID,
 		deviceHost: host,
 		devicePort: port,
 		filepath:   filepath,
 	}, nil
 }
 
+// DeviceID returns the ID of the device, empty if it's only known by its host &
+// port
+func (p *KDEConnect) DeviceID() string {
+	return p.deviceID
+}
+
 // FetchBackup returns the content of the backup file according to the filepath
 func (p *KDEConnect) FetchBackup() ([]byte, error) {
+	sshClient, sftpClient, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer sshClient.Close()
+	defer sftpClient.Close()
+
+	backupFileContents, err := readFile(sftpClient, p.filepath)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching backup file from KDE Connect device")
+	}
+
+	log.Print("Fetched andOTP backup file from KDE Connect device")
+
+	return backupFileContents, nil
+}
+
+// StoreBackup replaces the content of the backup file. The backup is uploaded
+// to a temporary file next to the backup file which is then renamed over the
+// backup file.
+func (p *KDEConnect) StoreBackup(backup []byte) error {
+	sshClient, sftpClient, err := p.connect()
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+	defer sftpClient.Close()
+
+	if err := replaceFile(sftpClient, p.filepath, backup); err != nil {
+		return errors.Wrap(err, "error storing backup file to KDE Connect device")
+	}
+
+	log.Print("Stored andOTP backup file to KDE Connect device")
+
+	return nil
+}
+
+// ReadDir lists the directory at the filepath
+func (p *KDEConnect) ReadDir() ([]os.FileInfo, error) {
+	sshClient, sftpClient, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer sshClient.Close()
+	defer sftpClient.Close()
+
+	entries, err := sftpClient.ReadDir(p.filepath)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing directory of KDE Connect device")
+	}
+
+	return entries, nil
+}
+
+// connect opens an SFTP session to the KDE Connect device
+func (p *KDEConnect) connect() (*ssh.Client, *sftp.Client, error) {
 	// Read & parse private key file
 	sshKeyBytes, err := ioutil.ReadFile(kdeconnect_ssh_key)
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading KDE Connect SSH private key")
+		return nil, nil, errors.Wrap(err, "error reading KDE Connect SSH private key")
 	}
 
 	sshKeySigner, err := ssh.ParsePrivateKey(sshKeyBytes)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating signer from KDE Connect SSH private key")
+		return nil, nil, errors.Wrap(err, "error creating signer from KDE Connect SSH private key")
 	}
 
 	// Connect via SSH
+	hostKey, err := p.hostKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	sshConfig := &ssh.ClientConfig{
 		User:            kdeconnect_ssh_username,
 		Auth:            []ssh.AuthMethod{ssh.PublicKeys(sshKeySigner)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: p.hostKeyCallback(hostKey),
+	}
+
+	if hostKey != nil {
+		sshConfig.HostKeyAlgorithms = hostKeyAlgorithms([]ssh.PublicKey{hostKey})
 	}
 
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", p.deviceHost, p.devicePort), sshConfig)
+	sshClient, err := ssh.Dial("tcp", net.JoinHostPort(p.deviceHost, p.devicePort), sshConfig)
 	if err != nil {
-		return nil, errors.Wrapf(
+		return nil, nil, errors.Wrapf(
 			err,
 			"error connecting to KDE Connect device at %s:%s",
 			p.deviceHost, p.devicePort,
 		)
 	}
-	defer sshClient.Close()
 
 	// Create SFTP client
 	sftpClient, err := sftp.NewClient(sshClient)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating SFTP client for KDE Connect device")
-	}
-
-	// Read the file
-	backupFile, err := sftpClient.Open(p.filepath)
-	if err != nil {
-		return nil, errors.Wrap(err, "error opening backup file from KDE Connect device")
+		sshClient.Close()
+		return nil, nil, errors.Wrap(err, "error creating SFTP client for KDE Connect device")
 	}
 
-	backupFileContents, err := ioutil.ReadAll(backupFile)
-	if err != nil {
-		return nil, errors.Wrap(err, "error reading backup file from KDE Connect device")
-	}
-
-	log.Print("Fetched andOTP backup file from KDE Connect device")
-
-	return backupFileContents, nil
+	return sshClient, sftpClient, nil
 }
@@ -0,0 +1,181 @@
+package backupprovider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/putrasattvika/andotp-cli/pkg/kdeconnect"
+)
+
+// pinnedHostKeysPath returns the path of the file holding the host keys pinned
+// on first use, $XDG_CONFIG_HOME/andotp-cli/known_hosts or
+// ~/.config/andotp-cli/known_hosts. Each line is a device ID (or host for
+// devices given by host & port) followed by the host key in authorized_keys
+// format.
+func pinnedHostKeysPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+
+	if configHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "unable to find home directory")
+		}
+
+		configHome = filepath.Join(homeDir, ".config")
+	}
+
+	return filepath.Join(configHome, "andotp-cli", "known_hosts"), nil
+}
+
+// hostKey returns the expected host key of the device. The device is verified
+// against the certificate KDE Connect stored when pairing, falling back to the
+// host key pinned on first use. Returns a nil key if the host key is not
+// pinned yet.
+func (p *KDEConnect) hostKey() (ssh.PublicKey, error) {
+	if p.deviceID != "" {
+		publicKey, err := kdeconnect.TrustedPublicKey(p.deviceID)
+		if err == nil {
+			hostKey, err := ssh.NewPublicKey(publicKey)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unsupported KDE Connect certificate of device %s", p.deviceID)
+			}
+
+			return hostKey, nil
+		}
+
+		if err != kdeconnect.ErrNotPaired {
+			return nil, err
+		}
+
+		log.Printf(
+			"KDE Connect stored no certificate for device %s, verifying it against its pinned host key",
+			p.deviceID,
+		)
+	}
+
+	pinnedHostKeys, err := readPinnedHostKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return pinnedHostKeys[p.pinName()], nil
+}
+
+// pinName returns the name of the device in the pinned host keys file
+func (p *KDEConnect) pinName() string {
+	if p.deviceID != "" {
+		return p.deviceID
+	}
+
+	// The SFTP port changes on each "Browse this device", only pin the host
+	return p.deviceHost
+}
+
+// hostKeyCallback returns an ssh.HostKeyCallback accepting only the expected
+// host key, or pinning the device's host key if there's none yet
+func (p *KDEConnect) hostKeyCallback(expected ssh.PublicKey) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if expected == nil {
+			return pinHostKey(p.pinName(), key)
+		}
+
+		if !bytes.Equal(key.Marshal(), expected.Marshal()) {
+			return fmt.Errorf(
+				"host key of KDE Connect device %s (%s) does NOT match the paired or pinned key "+
+					"(expected %s, got %s), someone may be impersonating the device",
+				p.pinName(), hostname, ssh.FingerprintSHA256(expected), ssh.FingerprintSHA256(key),
+			)
+		}
+
+		return nil
+	}
+}
+
+// readPinnedHostKeys reads the pinned host keys file. A missing file has no
+// pinned host keys.
+func readPinnedHostKeys() (map[string]ssh.PublicKey, error) {
+	path, err := pinnedHostKeysPath()
+	if err != nil {
+		return nil, err
+	}
+
+	pinnedHostKeys := make(map[string]ssh.PublicKey)
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return pinnedHostKeys, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "error reading pinned host keys")
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid pinned host key at %s:%d", path, lineNumber)
+		}
+
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(fields[1]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pinned host key at %s:%d", path, lineNumber)
+		}
+
+		pinnedHostKeys[fields[0]] = key
+	}
+
+	return pinnedHostKeys, nil
+}
+
+// pinHostKey appends the host key of the device to the pinned host keys file
+func pinHostKey(name string, key ssh.PublicKey) error {
+	path, err := pinnedHostKeysPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "error creating pinned host keys directory")
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "error opening pinned host keys")
+	}
+
+	line := name + " " + string(ssh.MarshalAuthorizedKey(key))
+
+	if _, err := file.WriteString(line); err != nil {
+		file.Close()
+		return errors.Wrap(err, "error pinning host key")
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "error pinning host key")
+	}
+
+	log.Printf(
+		"Pinned host key %s of KDE Connect device %s in %s on first use",
+		ssh.FingerprintSHA256(key), name, path,
+	)
+
+	return nil
+}
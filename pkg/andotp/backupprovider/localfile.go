@@ -2,12 +2,14 @@ package backupprovider
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 )
 
 // LocalFile provides andOTP backup from a local file.
-// Implements BackupProvider.
+// Implements BackupProvider and BackupWriter.
 type LocalFile struct {
 	filepath string
 }
@@ -26,3 +28,63 @@ func (p *LocalFile) FetchBackup() ([]byte, error) {
 
 	return backupBytes, nil
 }
+
+// StoreBackup replaces the content of the backup file. The backup is written
+// to a temporary file in the same directory which is then renamed over the
+// backup file.
+func (p *LocalFile) StoreBackup(backup []byte) error {
+	dir, name := filepath.Split(p.filepath)
+	if dir == "" {
+		dir = "."
+	}
+
+	// Keep the permission of an existing backup file
+	mode := os.FileMode(0600)
+	if stat, err := os.Stat(p.filepath); err == nil {
+		mode = stat.Mode().Perm()
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, "."+name+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary backup file")
+	}
+
+	tmpFilepath := tmpFile.Name()
+
+	if err := writeAndSync(tmpFile, backup, mode); err != nil {
+		os.Remove(tmpFilepath)
+		return err
+	}
+
+	if err := os.Rename(tmpFilepath, p.filepath); err != nil {
+		os.Remove(tmpFilepath)
+		return errors.Wrap(err, "unable to replace backup file")
+	}
+
+	// Sync the directory so that the rename itself is persisted
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
+
+// writeAndSync writes the backup into the file, flushes it to disk and closes it
+func writeAndSync(file *os.File, backup []byte, mode os.FileMode) error {
+	defer file.Close()
+
+	if err := file.Chmod(mode); err != nil {
+		return errors.Wrap(err, "unable to set temporary backup file permission")
+	}
+
+	if _, err := file.Write(backup); err != nil {
+		return errors.Wrap(err, "unable to write temporary backup file")
+	}
+
+	if err := file.Sync(); err != nil {
+		return errors.Wrap(err, "unable to sync temporary backup file")
+	}
+
+	return nil
+}
@@ -0,0 +1,318 @@
+package backupprovider
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Default SSH identity files, in the order OpenSSH tries them
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// SFTP provides andOTP backup from a file on an SSH server, e.g. a NAS.
+// Implements BackupProvider and BackupWriter.
+//
+// The server's host key is verified against the known_hosts files. The client
+// authenticates with the keys of the running ssh-agent, then with the
+// identity files.
+type SFTP struct {
+	user     string
+	address  string
+	filepath string
+
+	identityFiles   []string
+	knownHostsFiles []string
+}
+
+// NewSFTPFromURI creates a new SFTP backup provider from a
+// sftp://user@host:port/path URI. The user defaults to the current user, the
+// port to 22. A path starting with /~/ is relative to the user's home
+// directory.
+//
+// The URI query can set the identity files (identity=~/.ssh/nas_ed25519,
+// repeatable) and known_hosts files (known_hosts=~/.ssh/known_hosts,
+// repeatable). They default to ~/.ssh/id_ed25519, ~/.ssh/id_ecdsa,
+// ~/.ssh/id_rsa and ~/.ssh/known_hosts.
+func NewSFTPFromURI(uri *url.URL) (*SFTP, error) {
+	if uri.Hostname() == "" {
+		return nil, errors.New("SFTP URI has no host")
+	}
+
+	if uri.Path == "" || uri.Path == "/" {
+		return nil, errors.New("SFTP URI has no path")
+	}
+
+	p := &SFTP{filepath: uri.Path}
+
+	// Paths are absolute, except for the home-relative /~/ prefix which SFTP
+	// servers resolve relative to the login directory
+	if strings.HasPrefix(p.filepath, "/~/") {
+		p.filepath = strings.TrimPrefix(p.filepath, "/~/")
+	}
+
+	p.user = uri.User.Username()
+	if p.user == "" {
+		currentUser, err := user.Current()
+		if err != nil {
+			return nil, errors.Wrap(err, "SFTP URI has no user and the current user is unknown")
+		}
+
+		p.user = currentUser.Username
+	}
+
+	port := uri.Port()
+	if port == "" {
+		port = "22"
+	}
+
+	p.address = net.JoinHostPort(uri.Hostname(), port)
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find home directory")
+	}
+
+	query := uri.Query()
+
+	for _, identityFile := range query["identity"] {
+		p.identityFiles = append(p.identityFiles, expandHome(identityFile, homeDir))
+	}
+
+	if len(p.identityFiles) == 0 {
+		for _, name := range defaultIdentityFiles {
+			p.identityFiles = append(p.identityFiles, filepath.Join(homeDir, ".ssh", name))
+		}
+	}
+
+	for _, knownHostsFile := range query["known_hosts"] {
+		p.knownHostsFiles = append(p.knownHostsFiles, expandHome(knownHostsFile, homeDir))
+	}
+
+	if len(p.knownHostsFiles) == 0 {
+		p.knownHostsFiles = []string{filepath.Join(homeDir, ".ssh", "known_hosts")}
+	}
+
+	return p, nil
+}
+
+// FetchBackup returns the content of the backup file according to the filepath
+func (p *SFTP) FetchBackup() ([]byte, error) {
+	sshClient, sftpClient, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer sshClient.Close()
+	defer sftpClient.Close()
+
+	backupFileContents, err := readFile(sftpClient, p.filepath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error fetching backup file from %s", p.address)
+	}
+
+	log.Printf("Fetched andOTP backup file from %s", p.address)
+
+	return backupFileContents, nil
+}
+
+// StoreBackup replaces the content of the backup file. The backup is uploaded
+// to a temporary file next to the backup file which is then renamed over the
+// backup file.
+func (p *SFTP) StoreBackup(backup []byte) error {
+	sshClient, sftpClient, err := p.connect()
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+	defer sftpClient.Close()
+
+	if err := replaceFile(sftpClient, p.filepath, backup); err != nil {
+		return errors.Wrapf(err, "error storing backup file to %s", p.address)
+	}
+
+	log.Printf("Stored andOTP backup file to %s", p.address)
+
+	return nil
+}
+
+// connect opens an SFTP session to the server
+func (p *SFTP) connect() (*ssh.Client, *sftp.Client, error) {
+	hostKeyCallback, err := knownhosts.New(p.existingKnownHostsFiles()...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error reading known_hosts files")
+	}
+
+	authMethods, closeAgent := p.authMethods()
+	defer closeAgent()
+
+	if len(authMethods) == 0 {
+		return nil, nil, errors.New("no SSH authentication method available, start ssh-agent or set an identity file")
+	}
+
+	// The SSH handshake error loses the type of the host key error, keep it
+	// to explain verification failures
+	var hostKeyErr error
+
+	sshConfig := &ssh.ClientConfig{
+		User: p.user,
+		Auth: authMethods,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKeyErr = hostKeyCallback(hostname, remote, key)
+			return hostKeyErr
+		},
+		HostKeyAlgorithms: knownHostKeyAlgorithms(hostKeyCallback, p.address),
+	}
+
+	sshClient, err := ssh.Dial("tcp", p.address, sshConfig)
+	if err != nil {
+		if hostKeyErr != nil {
+			return nil, nil, hostKeyError(hostKeyErr, p.address)
+		}
+
+		return nil, nil, errors.Wrapf(err, "error connecting to %s", p.address)
+	}
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, nil, errors.Wrapf(err, "error creating SFTP client for %s", p.address)
+	}
+
+	return sshClient, sftpClient, nil
+}
+
+// existingKnownHostsFiles returns the known_hosts files that exist, since
+// knownhosts.New fails on missing files
+func (p *SFTP) existingKnownHostsFiles() []string {
+	existing := []string{}
+
+	for _, knownHostsFile := range p.knownHostsFiles {
+		if _, err := os.Stat(knownHostsFile); err == nil {
+			existing = append(existing, knownHostsFile)
+		}
+	}
+
+	return existing
+}
+
+// authMethods returns the ssh-agent and identity file authentication methods,
+// and a function closing the ssh-agent connection
+func (p *SFTP) authMethods() ([]ssh.AuthMethod, func()) {
+	authMethods := []ssh.AuthMethod{}
+	closeAgent := func() {}
+
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			log.Printf("Unable to connect to ssh-agent, skipping it: %v", err)
+		} else {
+			authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+			closeAgent = func() { conn.Close() }
+		}
+	}
+
+	signers := []ssh.Signer{}
+
+	for _, identityFile := range p.identityFiles {
+		keyBytes, err := ioutil.ReadFile(identityFile)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			log.Printf("Unable to read SSH identity file %s, skipping it: %v", identityFile, err)
+			continue
+		}
+
+		signer, err := ssh.ParsePrivateKey(keyBytes)
+
+		var passphraseErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseErr) {
+			log.Printf("SSH identity file %s is passphrase-protected, add it to ssh-agent to use it", identityFile)
+			continue
+		} else if err != nil {
+			log.Printf("Unable to parse SSH identity file %s, skipping it: %v", identityFile, err)
+			continue
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if len(signers) > 0 {
+		authMethods = append(authMethods, ssh.PublicKeys(signers...))
+	}
+
+	return authMethods, closeAgent
+}
+
+// knownHostKeyAlgorithms returns the algorithms of the host's keys in the
+// known_hosts files, so that the server is asked for a key type we can verify.
+// Returns nil for unknown hosts, i.e. the default algorithms.
+func knownHostKeyAlgorithms(hostKeyCallback ssh.HostKeyCallback, address string) []string {
+	// Checking a key that can't be known reports the known keys of the host
+	placeholderKey, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	remote := &net.TCPAddr{}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		remote.IP = net.ParseIP(host)
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(hostKeyCallback(address, remote, placeholderKey), &keyErr) || len(keyErr.Want) == 0 {
+		return nil
+	}
+
+	knownKeys := []ssh.PublicKey{}
+	for _, known := range keyErr.Want {
+		knownKeys = append(knownKeys, known.Key)
+	}
+
+	return hostKeyAlgorithms(knownKeys)
+}
+
+// hostKeyError explains known_hosts verification failures
+func hostKeyError(err error, address string) error {
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			return fmt.Errorf(
+				"host key of %s is not in known_hosts, connect once with ssh to verify and add it",
+				address,
+			)
+		}
+
+		return fmt.Errorf(
+			"host key of %s does NOT match known_hosts (%s:%d), someone may be impersonating the server",
+			address, keyErr.Want[0].Filename, keyErr.Want[0].Line,
+		)
+	}
+
+	var revokedErr *knownhosts.RevokedError
+	if errors.As(err, &revokedErr) {
+		return fmt.Errorf("host key of %s is revoked in known_hosts", address)
+	}
+
+	return errors.Wrapf(err, "error connecting to %s", address)
+}
+
+// expandHome expands a leading ~/ to the home directory
+func expandHome(path string, homeDir string) string {
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(homeDir, path[2:])
+	}
+
+	return path
+}
@@ -0,0 +1,136 @@
+package backupprovider
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Suffixes of the temporary file uploaded next to the backup file before it's
+// renamed over the backup file, and of the old backup file kept aside meanwhile
+// on servers without atomic renames
+const (
+	sftpTmpSuffix = ".andotp-cli.tmp"
+	sftpOldSuffix = ".andotp-cli.old"
+)
+
+// readFile reads a whole file through SFTP
+func readFile(sftpClient *sftp.Client, filepath string) ([]byte, error) {
+	file, err := sftpClient.Open(filepath)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening backup file")
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading backup file")
+	}
+
+	return content, nil
+}
+
+// replaceFile replaces the content of a file through SFTP. The content is
+// uploaded to a temporary file next to the file which is then renamed over
+// it. The file keeps its permissions, or gets 0600 if it doesn't exist yet.
+func replaceFile(sftpClient *sftp.Client, filepath string, content []byte) error {
+	tmpFilepath := filepath + sftpTmpSuffix
+
+	mode := os.FileMode(0600)
+	if stat, err := sftpClient.Stat(filepath); err == nil {
+		mode = stat.Mode().Perm()
+	}
+
+	if err := uploadFile(sftpClient, tmpFilepath, content, mode); err != nil {
+		sftpClient.Remove(tmpFilepath)
+		return errors.Wrap(err, "error uploading backup file")
+	}
+
+	// Prefer the atomic posix-rename extension
+	if err := sftpClient.PosixRename(tmpFilepath, filepath); err == nil {
+		return nil
+	}
+
+	// A plain SFTP rename fails if the target exists on most servers, so the
+	// old backup file is renamed aside first and restored if the rename fails.
+	// The backup file is never removed before its replacement is in place.
+	oldFilepath := filepath + sftpOldSuffix
+	sftpClient.Remove(oldFilepath)
+
+	movedAside := true
+	if err := sftpClient.Rename(filepath, oldFilepath); os.IsNotExist(err) {
+		movedAside = false
+	} else if err != nil {
+		return errors.Wrapf(err, "error renaming old backup file aside, the new backup is at %s", tmpFilepath)
+	}
+
+	if err := sftpClient.Rename(tmpFilepath, filepath); err != nil {
+		if movedAside {
+			if restoreErr := sftpClient.Rename(oldFilepath, filepath); restoreErr != nil {
+				return errors.Wrapf(
+					err,
+					"error renaming uploaded backup file, the old backup is at %s and the new backup at %s",
+					oldFilepath, tmpFilepath,
+				)
+			}
+		}
+
+		return errors.Wrapf(err, "error renaming uploaded backup file, the new backup is at %s", tmpFilepath)
+	}
+
+	if movedAside {
+		sftpClient.Remove(oldFilepath)
+	}
+
+	return nil
+}
+
+// uploadFile writes the content into a new file with the given permissions
+// through SFTP. The server creates the file with its default permissions, so
+// they're changed before the content is written.
+func uploadFile(sftpClient *sftp.Client, filepath string, content []byte, mode os.FileMode) error {
+	file, err := sftpClient.OpenFile(filepath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		return err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// hostKeyAlgorithms returns the host key algorithms to ask the server for, so
+// that it presents one of the given keys
+func hostKeyAlgorithms(keys []ssh.PublicKey) []string {
+	algorithms := []string{}
+	seen := make(map[string]bool)
+
+	for _, key := range keys {
+		keyType := key.Type()
+		if seen[keyType] {
+			continue
+		}
+
+		seen[keyType] = true
+
+		// RSA keys are verified with the SHA-2 signature algorithms first
+		if keyType == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.SigAlgoRSASHA2512, ssh.SigAlgoRSASHA2256)
+		}
+
+		algorithms = append(algorithms, keyType)
+	}
+
+	return algorithms
+}
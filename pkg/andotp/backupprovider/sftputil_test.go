@@ -0,0 +1,110 @@
+//go:build !windows
+// +build !windows
+
+package backupprovider
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// pipeConn joins the ends of two pipes into a connection
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// newTestSFTPClient returns a client of an SFTP server serving the local
+// filesystem
+func newTestSFTPClient(t *testing.T) *sftp.Client {
+	t.Helper()
+
+	clientRead, serverWrite := io.Pipe()
+	serverRead, clientWrite := io.Pipe()
+
+	server, err := sftp.NewServer(pipeConn{serverRead, serverWrite})
+	if err != nil {
+		t.Fatalf("sftp.NewServer() error = %v", err)
+	}
+
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientRead, clientWrite)
+	if err != nil {
+		t.Fatalf("sftp.NewClientPipe() error = %v", err)
+	}
+
+	// Closing the server's end first ends the client's receive loop
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	return client
+}
+
+func TestReplaceFileKeepsMode(t *testing.T) {
+	// The server creates files with 0644 minus the umask
+	oldUmask := syscall.Umask(022)
+	defer syscall.Umask(oldUmask)
+
+	tests := []struct {
+		name     string
+		existing bool
+		mode     os.FileMode
+		wantMode os.FileMode
+	}{
+		{name: "private backup", existing: true, mode: 0600, wantMode: 0600},
+		{name: "group-readable backup", existing: true, mode: 0640, wantMode: 0640},
+		{name: "new backup", existing: false, wantMode: 0600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sftpClient := newTestSFTPClient(t)
+			backupPath := filepath.Join(t.TempDir(), "otp_accounts.json.aes")
+
+			if tt.existing {
+				if err := ioutil.WriteFile(backupPath, []byte("old"), tt.mode); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := os.Chmod(backupPath, tt.mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := replaceFile(sftpClient, backupPath, []byte("new")); err != nil {
+				t.Fatalf("replaceFile() error = %v", err)
+			}
+
+			content, err := ioutil.ReadFile(backupPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != "new" {
+				t.Errorf("content = %q, want %q", content, "new")
+			}
+
+			stat, err := os.Stat(backupPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := stat.Mode().Perm(); got != tt.wantMode {
+				t.Errorf("mode = %o, want %o", got, tt.wantMode)
+			}
+
+			if _, err := os.Stat(backupPath + sftpTmpSuffix); !os.IsNotExist(err) {
+				t.Errorf("temporary file left behind: %v", err)
+			}
+		})
+	}
+}
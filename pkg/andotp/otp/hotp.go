@@ -0,0 +1,35 @@
+package otp
+
+import (
+	"fmt"
+
+	"github.com/pquerna/otp/hotp"
+)
+
+// generateCodeHOTP generates a token for a HOTP key.
+//
+// Like the "next" button on andOTP, the key's counter is advanced first and the
+// token is generated for the new counter value, since the token for the stored
+// counter value may already have been used on the phone. The caller is
+// responsible for persisting the advanced counter back to the backup.
+func generateCodeHOTP(key *OTPKey, secret string) (string, error) {
+	if key.Counter < 0 {
+		return "", fmt.Errorf("invalid HOTP counter %d", key.Counter)
+	}
+
+	token, err := hotp.GenerateCodeCustom(
+		secret,
+		uint64(key.Counter+1),
+		hotp.ValidateOpts{
+			Digits:    key.Digits,
+			Algorithm: key.Algorithm,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	key.Counter++
+
+	return token, nil
+}
@@ -0,0 +1,121 @@
+package otp
+
+import (
+	"encoding/base32"
+	"fmt"
+
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pquerna/otp"
+)
+
+// SetSecret replaces the key's secret. The secret is normalized (whitespaces
+// removed, uppercased) and moved into a memguard enclave, wiping the source
+// slice.
+func (k *OTPKey) SetSecret(secret []byte) {
+	normalized := secret[:0]
+
+	for _, c := range secret {
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		}
+
+		normalized = append(normalized, c)
+	}
+
+	// Wipe the leftover bytes that were not overwritten by the normalization
+	for idx := len(normalized); idx < len(secret); idx++ {
+		secret[idx] = 0
+	}
+
+	k.secretEnclave = memguard.NewEnclave(normalized)
+}
+
+// Validate checks the key's fields and populates the parsed Digits and
+// Algorithm fields. Should be called after creating or editing a key.
+func (k *OTPKey) Validate() error {
+	if _, ok := otpTypeMapping[k.OTPType]; !ok {
+		return fmt.Errorf("unsupported OTP type '%s'", k.OTPType)
+	}
+
+	algorithm, ok := otpAlgorithmMapping[k.AlgorithmStr]
+	if !ok {
+		return fmt.Errorf("unsupported OTP algorithm '%s'", k.AlgorithmStr)
+	}
+
+	if k.DigitsInt <= 0 {
+		return fmt.Errorf("invalid OTP digits %d", k.DigitsInt)
+	}
+
+	if k.OTPType == "HOTP" {
+		if k.Counter < 0 {
+			return fmt.Errorf("invalid HOTP counter %d", k.Counter)
+		}
+	} else if k.Period <= 0 {
+		return fmt.Errorf("invalid OTP period %d", k.Period)
+	}
+
+	if k.secretEnclave == nil {
+		return fmt.Errorf("OTP secret cannot be empty")
+	}
+
+	secretBuf, err := k.secretEnclave.Open()
+	if err != nil {
+		memguard.SafePanic(err)
+	}
+
+	defer secretBuf.Destroy()
+
+	if _, err := decodeSecret(secretBuf.String()); err != nil {
+		return err
+	}
+
+	k.Algorithm = algorithm
+	k.Digits = otp.Digits(k.DigitsInt)
+
+	return nil
+}
+
+// DecodedSecret returns the base32-decoded secret of the key inside a memguard
+// buffer, which must be destroyed by the caller
+func (k *OTPKey) DecodedSecret() (*memguard.LockedBuffer, error) {
+	secretBuf, err := k.secretEnclave.Open()
+	if err != nil {
+		memguard.SafePanic(err)
+	}
+
+	defer secretBuf.Destroy()
+
+	secretBytes, err := decodeSecret(secretBuf.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// Wipes secretBytes
+	return memguard.NewBufferFromBytes(secretBytes), nil
+}
+
+// SetDecodedSecret replaces the key's secret with the base32 encoding of the
+// given raw secret. Wipes the source slice.
+func (k *OTPKey) SetDecodedSecret(secret []byte) {
+	encoded := make([]byte, base32.StdEncoding.WithPadding(base32.NoPadding).EncodedLen(len(secret)))
+	base32.StdEncoding.WithPadding(base32.NoPadding).Encode(encoded, secret)
+	memguardcore.Wipe(secret)
+
+	// Wipes encoded
+	k.SetSecret(encoded)
+}
+
+// SecretBuffer returns the base32-encoded secret of the key inside a memguard
+// buffer, which must be destroyed by the caller
+func (k *OTPKey) SecretBuffer() *memguard.LockedBuffer {
+	secretBuf, err := k.secretEnclave.Open()
+	if err != nil {
+		memguard.SafePanic(err)
+	}
+
+	return secretBuf
+}
@@ -10,10 +10,13 @@ import (
 	"github.com/pquerna/otp"
 )
 
-type otpGenerateCodeFunc func(secret string, period int, digits otp.Digits, algorithm otp.Algorithm) (string, error)
+type otpGenerateCodeFunc func(key *OTPKey, secret string) (string, error)
 
 var otpTypeMapping = map[string]otpGenerateCodeFunc{
 	"TOTP": generateCodeTOTP,
+	"HOTP": generateCodeHOTP,
+
+	"STEAM": generateCodeSteam,
 }
 
 var otpAlgorithmMapping = map[string]otp.Algorithm{
@@ -25,15 +28,24 @@ var otpAlgorithmMapping = map[string]otp.Algorithm{
 
 // OTPKey holds the structure of a single OTP key from backup
 type OTPKey struct {
-	Issuer       string `json:"issuer"`
-	Label        string `json:"label"`
-	Digits       otp.Digits
-	DigitsInt    int    `json:"digits"`
-	OTPType      string `json:"type"`
-	Algorithm    otp.Algorithm
-	AlgorithmStr string   `json:"algorithm"`
-	Period       int      `json:"period"`
-	Tags         []string `json:"tags"`
+	Issuer        string        `json:"issuer"`
+	Label         string        `json:"label"`
+	Digits        otp.Digits    `json:"-"`
+	DigitsInt     int           `json:"digits"`
+	OTPType       string        `json:"type"`
+	Algorithm     otp.Algorithm `json:"-"`
+	AlgorithmStr  string        `json:"algorithm"`
+	Period        int           `json:"period"`
+	Counter       int64         `json:"counter"`
+	Tags          []string      `json:"tags"`
+	Thumbnail     string        `json:"thumbnail"`
+	LastUsed      int64         `json:"last_used"`
+	UsedFrequency int           `json:"used_frequency"`
+
+	// ID of the key in the backup it was decoded from, if the backup format
+	// has one (e.g. an Aegis entry UUID). Used to update the key in place when
+	// the backup is written back.
+	SourceID string `json:"-"`
 
 	// Will always be empty if OTPKeysFromJSON() was used
 	Secret string `json:"secret"`
@@ -41,6 +53,24 @@ type OTPKey struct {
 	secretEnclave *memguard.Enclave
 }
 
+// otpKeyJSON is the andOTP JSON representation of a single OTP key. The period
+// is only written for time-based keys and the counter only for HOTP keys, the
+// same way andOTP itself does it.
+type otpKeyJSON struct {
+	Secret        string   `json:"secret"`
+	Issuer        string   `json:"issuer"`
+	Label         string   `json:"label"`
+	Digits        int      `json:"digits"`
+	OTPType       string   `json:"type"`
+	Algorithm     string   `json:"algorithm"`
+	Thumbnail     string   `json:"thumbnail,omitempty"`
+	LastUsed      int64    `json:"last_used"`
+	UsedFrequency int      `json:"used_frequency"`
+	Period        *int     `json:"period,omitempty"`
+	Counter       *int64   `json:"counter,omitempty"`
+	Tags          []string `json:"tags"`
+}
+
 // OTPKeysFromJSON parses OTP keys from andOTP JSON backup
 func OTPKeysFromJSON(otpJSON []byte) ([]*OTPKey, error) {
 	otpKeys := make([]*OTPKey, 0)
@@ -73,7 +103,63 @@ func OTPKeysFromJSON(otpJSON []byte) ([]*OTPKey, error) {
 	return otpKeys, nil
 }
 
-// GenerateCode generates an OTP token
+// OTPKeysToJSON serializes OTP keys into andOTP JSON backup format. The
+// secrets are read from their memguard enclaves, so the returned bytes should
+// be wiped by the caller once they're no longer needed.
+func OTPKeysToJSON(otpKeys []*OTPKey) ([]byte, error) {
+	otpKeysJSON := make([]otpKeyJSON, 0, len(otpKeys))
+
+	for _, otpKey := range otpKeys {
+		secretBuf, err := otpKey.secretEnclave.Open()
+		if err != nil {
+			memguard.SafePanic(err)
+		}
+
+		otpKeyJSON := otpKeyJSON{
+			Secret:        string(secretBuf.Bytes()),
+			Issuer:        otpKey.Issuer,
+			Label:         otpKey.Label,
+			Digits:        otpKey.DigitsInt,
+			OTPType:       otpKey.OTPType,
+			Algorithm:     otpKey.AlgorithmStr,
+			Thumbnail:     otpKey.Thumbnail,
+			LastUsed:      otpKey.LastUsed,
+			UsedFrequency: otpKey.UsedFrequency,
+			Tags:          otpKey.Tags,
+		}
+
+		if otpKey.OTPType == "HOTP" {
+			counter := otpKey.Counter
+			otpKeyJSON.Counter = &counter
+		} else {
+			period := otpKey.Period
+			otpKeyJSON.Period = &period
+		}
+
+		if otpKeyJSON.Tags == nil {
+			otpKeyJSON.Tags = []string{}
+		}
+
+		otpKeysJSON = append(otpKeysJSON, otpKeyJSON)
+
+		secretBuf.Destroy()
+	}
+
+	otpJSON, err := json.Marshal(otpKeysJSON)
+
+	// Run GC to remove the plaintext secrets from memory
+	otpKeysJSON = nil
+	runtime.GC()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return otpJSON, nil
+}
+
+// GenerateCode generates an OTP token. For counter-based keys (HOTP), the
+// counter is advanced before generating the token.
 func (k *OTPKey) GenerateCode() (string, error) {
 	if _, ok := otpTypeMapping[k.OTPType]; !ok {
 		return "", fmt.Errorf("unsupported OTP type '%s'", k.OTPType)
@@ -86,5 +172,5 @@ func (k *OTPKey) GenerateCode() (string, error) {
 
 	defer secretBuf.Destroy()
 
-	return otpTypeMapping[k.OTPType](secretBuf.String(), k.Period, k.Digits, k.Algorithm)
+	return otpTypeMapping[k.OTPType](k, secretBuf.String())
 }
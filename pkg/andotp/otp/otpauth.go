@@ -0,0 +1,132 @@
+package otp
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+)
+
+// OTPKeyFromURI parses an OTP key from a Key Uri Format URI, e.g.
+// otpauth://totp/GitHub:myuser?secret=JBSWY3DPEHPK3PXP&issuer=GitHub
+//
+// Ref: https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func OTPKeyFromURI(uri string) (*OTPKey, error) {
+	parsedURI, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid otpauth URI")
+	}
+
+	if parsedURI.Scheme != "otpauth" {
+		return nil, fmt.Errorf("invalid otpauth URI scheme '%s'", parsedURI.Scheme)
+	}
+
+	query := parsedURI.Query()
+
+	otpKey := &OTPKey{
+		OTPType:      strings.ToUpper(parsedURI.Host),
+		AlgorithmStr: "SHA1",
+		DigitsInt:    6,
+		Period:       30,
+		Tags:         []string{},
+		Thumbnail:    "Default",
+	}
+
+	// Some authenticators encode Steam Guard keys as TOTP keys with a custom
+	// encoder parameter
+	if otpKey.OTPType == "STEAM" || strings.EqualFold(query.Get("encoder"), "steam") {
+		otpKey.OTPType = "STEAM"
+		otpKey.DigitsInt = 5
+	}
+
+	// The label is either "issuer:account" or "account"
+	label := strings.TrimPrefix(parsedURI.Path, "/")
+	if idx := strings.Index(label, ":"); idx >= 0 {
+		otpKey.Issuer = strings.TrimSpace(label[:idx])
+		label = label[idx+1:]
+	}
+
+	otpKey.Label = strings.TrimSpace(label)
+
+	// The issuer parameter takes precedence over the label prefix
+	if issuer := query.Get("issuer"); issuer != "" {
+		otpKey.Issuer = issuer
+	}
+
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		otpKey.AlgorithmStr = strings.ToUpper(algorithm)
+	}
+
+	for param, field := range map[string]*int{"digits": &otpKey.DigitsInt, "period": &otpKey.Period} {
+		if value := query.Get(param); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid otpauth URI %s '%s'", param, value)
+			}
+
+			*field = parsed
+		}
+	}
+
+	if counter := query.Get("counter"); counter != "" {
+		parsed, err := strconv.ParseInt(counter, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid otpauth URI counter '%s'", counter)
+		}
+
+		otpKey.Counter = parsed
+	}
+
+	otpKey.SetSecret([]byte(query.Get("secret")))
+
+	if err := otpKey.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid otpauth URI")
+	}
+
+	return otpKey, nil
+}
+
+// URI returns the Key Uri Format URI of the OTP key, e.g.
+// otpauth://totp/GitHub:myuser?secret=JBSWY3DPEHPK3PXP&issuer=GitHub
+//
+// The returned URI contains the plaintext secret.
+func (k *OTPKey) URI() (string, error) {
+	if _, ok := otpTypeMapping[k.OTPType]; !ok {
+		return "", fmt.Errorf("unsupported OTP type '%s'", k.OTPType)
+	}
+
+	secretBuf, err := k.secretEnclave.Open()
+	if err != nil {
+		memguard.SafePanic(err)
+	}
+
+	defer secretBuf.Destroy()
+
+	query := url.Values{}
+	query.Set("secret", string(secretBuf.Bytes()))
+	query.Set("algorithm", k.AlgorithmStr)
+	query.Set("digits", strconv.Itoa(k.DigitsInt))
+
+	if k.OTPType == "HOTP" {
+		query.Set("counter", strconv.FormatInt(k.Counter, 10))
+	} else {
+		query.Set("period", strconv.Itoa(k.Period))
+	}
+
+	label := url.PathEscape(k.Label)
+
+	if k.Issuer != "" {
+		query.Set("issuer", k.Issuer)
+		label = url.PathEscape(k.Issuer) + ":" + label
+	}
+
+	// Encode spaces as %20 instead of '+', which some authenticators display
+	// literally. A literal '+' is already encoded as %2B.
+	return fmt.Sprintf(
+		"otpauth://%s/%s?%s",
+		strings.ToLower(k.OTPType), label, strings.ReplaceAll(query.Encode(), "+", "%20"),
+	), nil
+}
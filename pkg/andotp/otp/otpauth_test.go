@@ -0,0 +1,202 @@
+package otp
+
+import (
+	"reflect"
+	"testing"
+)
+
+// keyFields are the fields of an OTP key compared by the tests, with its
+// plaintext secret
+type keyFields struct {
+	Issuer    string
+	Label     string
+	OTPType   string
+	Algorithm string
+	Digits    int
+	Period    int
+	Counter   int64
+	Secret    string
+}
+
+func fieldsOf(k *OTPKey) keyFields {
+	secretBuf := k.SecretBuffer()
+	defer secretBuf.Destroy()
+
+	fields := keyFields{
+		Issuer:    k.Issuer,
+		Label:     k.Label,
+		OTPType:   k.OTPType,
+		Algorithm: k.AlgorithmStr,
+		Digits:    k.DigitsInt,
+		Secret:    string(secretBuf.Bytes()),
+	}
+
+	if k.OTPType == "HOTP" {
+		fields.Counter = k.Counter
+	} else {
+		fields.Period = k.Period
+	}
+
+	return fields
+}
+
+func TestOTPKeyFromURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    keyFields
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			uri:  "otpauth://totp/me@example.com?secret=JBSWY3DPEHPK3PXP",
+			want: keyFields{
+				Label: "me@example.com", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Period: 30,
+				Secret: "JBSWY3DPEHPK3PXP",
+			},
+		},
+		{
+			name: "issuer in label and parameter",
+			uri: "otpauth://totp/GitHub:me?secret=JBSWY3DPEHPK3PXP&issuer=GitHub" +
+				"&algorithm=sha256&digits=8&period=60",
+			want: keyFields{
+				Issuer: "GitHub", Label: "me", OTPType: "TOTP", Algorithm: "SHA256", Digits: 8, Period: 60,
+				Secret: "JBSWY3DPEHPK3PXP",
+			},
+		},
+		{
+			name: "issuer parameter takes precedence over the label prefix",
+			uri:  "otpauth://totp/Old%20Name:me?secret=JBSWY3DPEHPK3PXP&issuer=New%20Name",
+			want: keyFields{
+				Issuer: "New Name", Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Period: 30,
+				Secret: "JBSWY3DPEHPK3PXP",
+			},
+		},
+		{
+			name: "issuer prefix with spaces",
+			uri:  "otpauth://totp/ACME%20Co:%20john.doe?secret=JBSWY3DPEHPK3PXP",
+			want: keyFields{
+				Issuer: "ACME Co", Label: "john.doe", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Period: 30,
+				Secret: "JBSWY3DPEHPK3PXP",
+			},
+		},
+		{
+			name: "hotp with counter",
+			uri:  "otpauth://hotp/Acme:token?secret=GEZDGNBVGY3TQOJQ&counter=42&algorithm=SHA512",
+			want: keyFields{
+				Issuer: "Acme", Label: "token", OTPType: "HOTP", Algorithm: "SHA512", Digits: 6, Counter: 42,
+				Secret: "GEZDGNBVGY3TQOJQ",
+			},
+		},
+		{
+			name: "steam type",
+			uri:  "otpauth://steam/Steam:gamer?secret=JBSWY3DPEHPK3PXP",
+			want: keyFields{
+				Issuer: "Steam", Label: "gamer", OTPType: "STEAM", Algorithm: "SHA1", Digits: 5, Period: 30,
+				Secret: "JBSWY3DPEHPK3PXP",
+			},
+		},
+		{
+			name: "steam encoder",
+			uri:  "otpauth://totp/Steam:gamer?secret=JBSWY3DPEHPK3PXP&encoder=steam",
+			want: keyFields{
+				Issuer: "Steam", Label: "gamer", OTPType: "STEAM", Algorithm: "SHA1", Digits: 5, Period: 30,
+				Secret: "JBSWY3DPEHPK3PXP",
+			},
+		},
+		{
+			name: "secret normalized",
+			uri:  "otpauth://totp/me?secret=jbsw%20y3dp%20ehpk%203pxp",
+			want: keyFields{
+				Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Period: 30,
+				Secret: "JBSWY3DPEHPK3PXP",
+			},
+		},
+		{name: "wrong scheme", uri: "https://totp/me?secret=JBSWY3DPEHPK3PXP", wantErr: true},
+		{name: "unsupported type", uri: "otpauth://motp/me?secret=JBSWY3DPEHPK3PXP", wantErr: true},
+		{name: "unsupported algorithm", uri: "otpauth://totp/me?secret=JBSWY3DPEHPK3PXP&algorithm=SHA3", wantErr: true},
+		{name: "invalid digits", uri: "otpauth://totp/me?secret=JBSWY3DPEHPK3PXP&digits=six", wantErr: true},
+		{name: "invalid period", uri: "otpauth://totp/me?secret=JBSWY3DPEHPK3PXP&period=0", wantErr: true},
+		{name: "invalid counter", uri: "otpauth://hotp/me?secret=JBSWY3DPEHPK3PXP&counter=-", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otpKey, err := OTPKeyFromURI(tt.uri)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("OTPKeyFromURI() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("OTPKeyFromURI() error = %v", err)
+			}
+
+			if got := fieldsOf(otpKey); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OTPKeyFromURI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	tests := []keyFields{
+		{
+			Issuer: "GitHub", Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Period: 30,
+			Secret: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			Label: "no issuer", OTPType: "TOTP", Algorithm: "SHA256", Digits: 8, Period: 60,
+			Secret: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			Issuer: "Acme", Label: "token", OTPType: "HOTP", Algorithm: "SHA512", Digits: 6, Counter: 7,
+			Secret: "GEZDGNBVGY3TQOJQ",
+		},
+		{
+			Issuer: "Steam", Label: "gamer", OTPType: "STEAM", Algorithm: "SHA1", Digits: 5, Period: 30,
+			Secret: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			Issuer: "A+B & Co", Label: "first last+tag@example.com", OTPType: "TOTP", Algorithm: "MD5",
+			Digits: 6, Period: 30, Secret: "JBSWY3DPEHPK3PXP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Issuer+":"+tt.Label, func(t *testing.T) {
+			otpKey := &OTPKey{
+				Issuer:       tt.Issuer,
+				Label:        tt.Label,
+				OTPType:      tt.OTPType,
+				AlgorithmStr: tt.Algorithm,
+				DigitsInt:    tt.Digits,
+				Period:       tt.Period,
+				Counter:      tt.Counter,
+			}
+			otpKey.SetSecret([]byte(tt.Secret))
+
+			if err := otpKey.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			uri, err := otpKey.URI()
+			if err != nil {
+				t.Fatalf("URI() error = %v", err)
+			}
+
+			parsed, err := OTPKeyFromURI(uri)
+			if err != nil {
+				t.Fatalf("OTPKeyFromURI(%s) error = %v", uri, err)
+			}
+
+			if got := fieldsOf(parsed); !reflect.DeepEqual(got, tt) {
+				t.Errorf("round trip through %s = %+v, want %+v", uri, got, tt)
+			}
+		})
+	}
+}
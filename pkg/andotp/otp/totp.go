@@ -1,26 +1,80 @@
 package otp
 
 import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"strings"
 	"time"
 
-	"github.com/pquerna/otp"
+	"github.com/pkg/errors"
+
 	"github.com/pquerna/otp/totp"
 )
 
 // generateCodeTOTP generates a token for a TOTP key
-func generateCodeTOTP(
-	secret string,
-	period int,
-	digits otp.Digits,
-	algorithm otp.Algorithm,
-) (string, error) {
+func generateCodeTOTP(key *OTPKey, secret string) (string, error) {
 	return totp.GenerateCodeCustom(
 		secret,
 		time.Now(),
 		totp.ValidateOpts{
-			Period:    uint(period),
-			Digits:    digits,
-			Algorithm: algorithm,
+			Period:    uint(key.Period),
+			Digits:    key.Digits,
+			Algorithm: key.Algorithm,
 		},
 	)
 }
+
+// steamAlphabet is the alphabet used by Steam Guard tokens
+const steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+// steamDigits is the length of a Steam Guard token
+const steamDigits = 5
+
+// generateCodeSteam generates a token for a Steam Guard key. Steam Guard is a
+// 30-seconds HMAC-SHA1 TOTP whose truncated value is encoded into 5 characters
+// of Steam's alphabet instead of decimal digits.
+func generateCodeSteam(key *OTPKey, secret string) (string, error) {
+	period := key.Period
+	if period <= 0 {
+		period = 30
+	}
+
+	secretBytes, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(time.Now().Unix()/int64(period)))
+
+	mac := hmac.New(sha1.New, secretBytes)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	token := make([]byte, steamDigits)
+	for i := range token {
+		token[i] = steamAlphabet[value%uint32(len(steamAlphabet))]
+		value /= uint32(len(steamAlphabet))
+	}
+
+	return string(token), nil
+}
+
+// decodeSecret decodes a base32-encoded OTP secret, tolerating whitespaces,
+// lowercase letters and missing padding
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	secret = strings.TrimRight(secret, "=")
+
+	secretBytes, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base32 OTP secret")
+	}
+
+	return secretBytes, nil
+}
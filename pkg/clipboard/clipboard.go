@@ -0,0 +1,182 @@
+package clipboard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	systemclipboard "github.com/atotto/clipboard"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// HelperCommand is the hidden command of the detached helper process clearing
+// the clipboard, run as "<executable> HelperCommand <delay>"
+const HelperCommand = "__clear-clipboard"
+
+// HashEnv is the environment variable passing the hash of the copied token to
+// the helper process. It isn't passed as an argument since the arguments of a
+// process are visible to other users, and the hash of a short numeric token
+// is easy to reverse.
+const HashEnv = "ANDOTP_CLI_CLIPBOARD_HASH"
+
+// Clear delay of HOTP keys, which have no period
+const defaultClearAfter = 30 * time.Second
+
+// Environment variables passed to the helper process, the ones the clipboard
+// backends (xclip, xsel, wl-clipboard, pbcopy and the Windows API) need to
+// find and reach the clipboard. The rest of the environment may hold secrets,
+// e.g. the backup password given with --password-source env:<variable>.
+var helperEnv = []string{
+	"PATH",
+	"HOME",
+	"DISPLAY",
+	"XAUTHORITY",
+	"WAYLAND_DISPLAY",
+	"XDG_RUNTIME_DIR",
+	"DBUS_SESSION_BUS_ADDRESS",
+	"TMPDIR",
+	"SYSTEMROOT",
+	"USERPROFILE",
+}
+
+// ClearError is returned by CopyToken when the token was copied, but the
+// helper process clearing it can't be started
+type ClearError struct {
+	err error
+}
+
+func (e *ClearError) Error() string {
+	return "token copied but the clipboard won't be cleared: " + e.err.Error()
+}
+
+func (e *ClearError) Cause() error {
+	return e.err
+}
+
+// ClearAfter is how long a copied token stays in the clipboard
+type ClearAfter struct {
+	// Never clear the clipboard
+	Never bool
+
+	// Delay before clearing the clipboard, 0 for the remaining time of the
+	// key's current period
+	Delay time.Duration
+}
+
+// ParseClearAfter parses "period" (the remaining time of the key's current
+// period), "never" or a duration such as "45s"
+func ParseClearAfter(spec string) (ClearAfter, error) {
+	switch spec {
+	case "", "period":
+		return ClearAfter{}, nil
+	case "never":
+		return ClearAfter{Never: true}, nil
+	}
+
+	delay, err := time.ParseDuration(spec)
+	if err != nil {
+		return ClearAfter{}, errors.Wrap(err, "expected 'period', 'never' or a duration such as '45s'")
+	}
+
+	if delay <= 0 {
+		return ClearAfter{}, errors.New("duration must be positive")
+	}
+
+	return ClearAfter{Delay: delay}, nil
+}
+
+// delayFor returns the delay before clearing the token of the OTP key
+func (c ClearAfter) delayFor(otpKey *otp.OTPKey, now time.Time) time.Duration {
+	if c.Delay > 0 {
+		return c.Delay
+	}
+
+	if otpKey.OTPType == "HOTP" || otpKey.Period <= 0 {
+		return defaultClearAfter
+	}
+
+	period := int64(otpKey.Period)
+
+	return time.Duration(period-now.Unix()%period) * time.Second
+}
+
+// CopyToken copies the token of the OTP key to the clipboard, then starts a
+// detached helper process that clears the clipboard after the delay if it
+// still holds the token, even if this process exits in the meantime. Returns
+// the delay, 0 if the clipboard is never cleared. Returns a *ClearError if the
+// token was copied but the helper process can't be started.
+func CopyToken(token string, otpKey *otp.OTPKey, clearAfter ClearAfter) (time.Duration, error) {
+	if err := systemclipboard.WriteAll(token); err != nil {
+		return 0, err
+	}
+
+	if clearAfter.Never {
+		return 0, nil
+	}
+
+	delay := clearAfter.delayFor(otpKey, time.Now())
+
+	if err := startHelper(delay, hashToken(token)); err != nil {
+		return 0, &ClearError{err: err}
+	}
+
+	return delay, nil
+}
+
+// startHelper starts the detached helper process
+func startHelper(delay time.Duration, hash string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "unable to find the andotp-cli executable")
+	}
+
+	cmd := exec.Command(executable, HelperCommand, delay.String())
+	cmd.Env = []string{HashEnv + "=" + hash}
+
+	for _, name := range helperEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+
+	cmd.SysProcAttr = detachedProcAttr()
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "unable to start clipboard helper")
+	}
+
+	return cmd.Process.Release()
+}
+
+// RunHelper is the detached helper process: it waits for the delay, then
+// clears the clipboard if it still holds the token with the hash in HashEnv
+func RunHelper(delay time.Duration) error {
+	hash := os.Getenv(HashEnv)
+	if hash == "" {
+		return errors.New(HashEnv + " is not set")
+	}
+
+	time.Sleep(delay)
+
+	content, err := systemclipboard.ReadAll()
+	if err != nil {
+		return errors.Wrap(err, "unable to read clipboard")
+	}
+
+	// Something else was copied since, leave it alone
+	if hashToken(strings.TrimRight(content, "\r\n")) != hash {
+		return nil
+	}
+
+	return systemclipboard.WriteAll("")
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
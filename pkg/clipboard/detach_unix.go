@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package clipboard
+
+import "syscall"
+
+// detachedProcAttr starts the helper in its own session, so that it survives
+// the terminal closing
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
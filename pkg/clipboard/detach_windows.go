@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package clipboard
+
+import "syscall"
+
+// DETACHED_PROCESS, missing from the syscall package
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts the helper without a console, in its own process
+// group, so that it survives the console closing
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+		HideWindow:    true,
+	}
+}
@@ -0,0 +1,135 @@
+package code
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/clipboard"
+	"github.com/putrasattvika/andotp-cli/pkg/code/config"
+	"github.com/putrasattvika/andotp-cli/pkg/loader"
+	"github.com/putrasattvika/andotp-cli/pkg/lookup"
+	"github.com/putrasattvika/andotp-cli/pkg/usage"
+)
+
+// Exit codes of the "code" command
+const (
+	ExitCodeOK          = 0
+	ExitCodeError       = 1
+	ExitCodeNotFound    = 2
+	ExitCodeAmbiguous   = 3
+	ExitCodeBackupError = 4
+)
+
+// exitError is an error with an associated exit code
+type exitError struct {
+	err      error
+	exitCode int
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Cause() error {
+	return e.err
+}
+
+// ExitCode returns the process exit code appropriate for the error returned
+// by Code.Run()
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitCodeOK
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.exitCode
+	}
+
+	return ExitCodeError
+}
+
+// Code generates a single token without an interactive session
+type Code struct {
+	config *config.Config
+}
+
+// Create a new Code
+func NewCode(config *config.Config) (*Code, error) {
+	return &Code{config: config}, nil
+}
+
+// Run generates the token of the OTP key matching the query, then prints it to
+// stdout or copies it to the clipboard
+func (c *Code) Run() error {
+	loader_, err := loader.NewLoader(c.config.Loader)
+	if err != nil {
+		return &exitError{err: err, exitCode: ExitCodeBackupError}
+	}
+
+	backup, err := loader_.Load()
+	if err != nil {
+		return &exitError{
+			err:      errors.Wrap(err, "unable to load OTP keys from andOTP backup file"),
+			exitCode: ExitCodeBackupError,
+		}
+	}
+
+	usageStore := usage.OpenOrEmpty()
+
+	resolve := lookup.Resolve
+	if c.config.Fuzzy {
+		resolve = lookup.ResolveFuzzy
+	}
+
+	_, otpKey, err := resolve(backup.OTPKeys, c.config.Query, usageStore.Frequency)
+	if err != nil {
+		var ambiguousErr *lookup.AmbiguousError
+		if errors.As(err, &ambiguousErr) {
+			return &exitError{err: err, exitCode: ExitCodeAmbiguous}
+		}
+
+		return &exitError{err: err, exitCode: ExitCodeNotFound}
+	}
+
+	token, err := otpKey.GenerateCode()
+	if err != nil {
+		return errors.Wrap(err, "error during token generation")
+	}
+
+	// Persist the advanced HOTP counter so that the next token is different
+	if otpKey.OTPType == "HOTP" {
+		if err := loader_.Store(backup, ""); err != nil {
+			log.Printf("HOTP counter advanced to %d but cannot be stored: %v", otpKey.Counter, err)
+		} else {
+			log.Printf("HOTP counter advanced to %d and stored", otpKey.Counter)
+		}
+	}
+
+	if err := usageStore.Record(otpKey); err != nil {
+		log.Printf("Unable to record OTP key usage: %v", err)
+	}
+
+	if !c.config.Clipboard {
+		fmt.Println(token)
+		return nil
+	}
+
+	clearDelay, err := clipboard.CopyToken(token, otpKey, c.config.ClipboardClearAfter)
+
+	var clearErr *clipboard.ClearError
+
+	if errors.As(err, &clearErr) {
+		log.Printf("Warning: %v", err)
+	} else if err != nil {
+		return errors.Wrap(err, "cannot copy token to clipboard")
+	} else if clearDelay > 0 {
+		log.Printf("Token copied to clipboard, clearing it in %s", clearDelay)
+	} else {
+		log.Print("Token copied to clipboard")
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/clipboard"
+	loaderconfig "github.com/putrasattvika/andotp-cli/pkg/loader/config"
+)
+
+// Configuration used to generate a single token non-interactively
+type Config struct {
+	// Configuration for loading the andOTP backup
+	Loader *loaderconfig.Config
+
+	// Index, issuer, label or tag of the OTP key to generate the token for
+	Query string
+
+	// Copy the token to the clipboard instead of printing it to stdout
+	Clipboard bool
+
+	// When to clear the token copied to the clipboard
+	ClipboardClearAfter clipboard.ClearAfter
+
+	// Fall back to the best fuzzy match if no OTP key matches the query
+	// exactly
+	Fuzzy bool
+}
+
+func ParseCmdConfig(cmdConfig *cmdconfig.Config, cmdCodeConfig *cmdconfig.CodeConfig) (*Config, error) {
+	loaderConfig, err := loaderconfig.ParseCmdConfig(cmdConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// <query>
+	if strings.TrimSpace(cmdCodeConfig.Query) == "" {
+		return nil, errors.New("OTP key query cannot be empty")
+	}
+
+	// --clipboard-clear-after
+	clearAfter, err := clipboard.ParseClearAfter(cmdConfig.ClipboardClearAfter)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid --clipboard-clear-after")
+	}
+
+	return &Config{
+		Loader:    loaderConfig,
+		Query:     cmdCodeConfig.Query,
+		Clipboard: cmdCodeConfig.Clipboard,
+		Fuzzy:     cmdCodeConfig.Fuzzy,
+
+		ClipboardClearAfter: clearAfter,
+	}, nil
+}
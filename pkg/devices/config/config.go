@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+)
+
+// Output formats of the "devices" commands
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+// Configuration used to inspect KDE Connect devices
+type Config struct {
+	// Output format, FormatTable or FormatJSON
+	Format string
+
+	// Name or ID of the KDE Connect device to browse
+	Device string
+
+	// Absolute directory of the device to list
+	Path string
+}
+
+func ParseCmdConfig(cmdDevicesConfig *cmdconfig.DevicesConfig) (*Config, error) {
+	// --format
+	if cmdDevicesConfig.Format != FormatTable && cmdDevicesConfig.Format != FormatJSON {
+		return nil, fmt.Errorf(
+			"unsupported output format '%s', must be %s or %s",
+			cmdDevicesConfig.Format, FormatTable, FormatJSON,
+		)
+	}
+
+	// <path>, relative to the root of the device's filesystem
+	dirpath := "/"
+	if cmdDevicesConfig.Path != "" {
+		dirpath = path.Clean("/" + cmdDevicesConfig.Path)
+	}
+
+	return &Config{
+		Format: cmdDevicesConfig.Format,
+		Device: strings.TrimSpace(cmdDevicesConfig.Device),
+		Path:   dirpath,
+	}, nil
+}
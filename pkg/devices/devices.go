@@ -0,0 +1,204 @@
+package devices
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/backupprovider"
+	"github.com/putrasattvika/andotp-cli/pkg/devices/config"
+	"github.com/putrasattvika/andotp-cli/pkg/kdeconnect"
+)
+
+// Name prefix and extensions of the backups andOTP creates, e.g.
+// otp_accounts_2021-05-06_12-00-00.json.aes
+var (
+	backupPrefix     = "otp_accounts"
+	backupExtensions = []string{".json", ".json.aes", ".json.gpg"}
+)
+
+// Devices inspects KDE Connect devices and browses their filesystem
+type Devices struct {
+	config *config.Config
+}
+
+// Entry is a file or directory of a device's filesystem
+type Entry struct {
+	Name     string    `json:"name"`
+	Dir      bool      `json:"dir"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+
+	// Whether the entry is the latest andOTP backup of the directory
+	LatestBackup bool `json:"latest_backup"`
+}
+
+// Create a new Devices
+func NewDevices(config *config.Config) (*Devices, error) {
+	return &Devices{config: config}, nil
+}
+
+// List prints the KDE Connect devices known to the host
+func (d *Devices) List() error {
+	client, err := kdeconnect.NewSessionClient()
+	if err != nil {
+		return errors.Wrap(err, "error connecting to KDE Connect")
+	}
+
+	devices, err := client.ListDevices()
+	if err != nil {
+		return err
+	}
+
+	if d.config.Format == config.FormatJSON {
+		return printJSON(devices)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tREACHABLE\tPAIRED\tSFTP")
+
+	for _, device := range devices {
+		sftpEndpoint := "-"
+		if device.SFTPHost != "" && device.SFTPPort != "" {
+			sftpEndpoint = device.SFTPHost + ":" + device.SFTPPort
+		}
+
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%s\n",
+			device.Name, device.ID, yesNo(device.Reachable), yesNo(device.Paired), sftpEndpoint,
+		)
+	}
+
+	return w.Flush()
+}
+
+// Ls prints the content of a directory of a KDE Connect device, highlighting
+// the latest andOTP backup in it
+func (d *Devices) Ls() error {
+	provider, err := backupprovider.NewKDEConnect(d.config.Device, d.config.Path)
+	if err != nil {
+		return err
+	}
+
+	fileInfos, err := provider.ReadDir()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]*Entry, 0, len(fileInfos))
+	var latestBackup *Entry
+
+	for _, fileInfo := range fileInfos {
+		entry := &Entry{
+			Name:     fileInfo.Name(),
+			Dir:      fileInfo.IsDir(),
+			Size:     fileInfo.Size(),
+			Modified: fileInfo.ModTime(),
+		}
+
+		if !entry.Dir && isBackup(entry.Name) &&
+			(latestBackup == nil || entry.Modified.After(latestBackup.Modified)) {
+			latestBackup = entry
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if latestBackup != nil {
+		latestBackup.LatestBackup = true
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	if d.config.Format == config.FormatJSON {
+		return printJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tMODIFIED\tSIZE\tNAME")
+
+	for _, entry := range entries {
+		marker, name := "", entry.Name
+
+		if entry.Dir {
+			name += "/"
+		} else if entry.LatestBackup {
+			marker = "*"
+		}
+
+		fmt.Fprintf(
+			w, "%s\t%s\t%d\t%s\n",
+			marker, entry.Modified.Local().Format("2006-01-02 15:04"), entry.Size, name,
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if latestBackup != nil {
+		fmt.Printf(
+			"\n* Latest andOTP backup, use it with:\n  --backup-file-uri %s\n",
+			backupFileURI(provider.DeviceID(), path.Join(d.config.Path, latestBackup.Name)),
+		)
+	}
+
+	return nil
+}
+
+// backupFileURI returns the KDE Connect URI of a file of the device, escaping
+// each segment of its path. The device is identified by its ID, which unlike
+// its name is unique and can't contain a slash.
+func backupFileURI(deviceID string, filepath string) string {
+	segments := strings.Split(filepath, "/")
+	for idx, segment := range segments {
+		segments[idx] = url.PathEscape(segment)
+	}
+
+	return "kdeconnect://_/" + url.PathEscape(deviceID) + strings.Join(segments, "/")
+}
+
+// isBackup returns true if the file name is one of an andOTP backup
+func isBackup(name string) bool {
+	if !strings.HasPrefix(name, backupPrefix) {
+		return false
+	}
+
+	for _, extension := range backupExtensions {
+		if strings.HasSuffix(name, extension) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// printJSON prints the value as indented JSON to stdout
+func printJSON(v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error encoding JSON")
+	}
+
+	_, err = fmt.Println(string(content))
+	return err
+}
+
+// yesNo formats a boolean for the table output
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
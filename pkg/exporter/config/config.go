@@ -0,0 +1,72 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+	loaderconfig "github.com/putrasattvika/andotp-cli/pkg/loader/config"
+	"github.com/putrasattvika/andotp-cli/pkg/password"
+)
+
+// Configuration used to export the OTP keys of a backup
+type Config struct {
+	// Configuration for loading the andOTP backup
+	Loader *loaderconfig.Config
+
+	// Format of the exported OTP keys
+	Format string
+
+	// Path to write the exported OTP keys to, stdout if empty
+	Output string
+
+	// Show the exported URIs as terminal QR codes
+	QR bool
+
+	// Where to read the password of encrypted export formats from
+	PasswordSource password.Source
+
+	// Only export OTP keys having any of these tags, all OTP keys if empty
+	Tags []string
+
+	// Confirmation for exporting to unencrypted formats
+	IUnderstandPlaintext bool
+}
+
+func ParseCmdConfig(cmdConfig *cmdconfig.Config, cmdExportConfig *cmdconfig.ExportConfig) (*Config, error) {
+	loaderConfig, err := loaderconfig.ParseCmdConfig(cmdConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// --format
+	if cmdExportConfig.Format == "" {
+		return nil, errors.New("--format cannot be empty")
+	}
+
+	// --output
+	output := cmdExportConfig.Output
+	if output == "-" {
+		output = ""
+	}
+
+	// --vault-password-source
+	passwordSource, err := password.ParseSource(cmdExportConfig.VaultPasswordSource)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid --vault-password-source")
+	}
+
+	if prompt, ok := passwordSource.(*password.Prompt); ok {
+		prompt.Message = "Enter export password: "
+	}
+
+	return &Config{
+		Loader: loaderConfig,
+		Format: cmdExportConfig.Format,
+		Output: output,
+		QR:     cmdExportConfig.QR,
+
+		PasswordSource: passwordSource,
+
+		Tags:                 cmdExportConfig.Tags,
+		IUnderstandPlaintext: cmdExportConfig.IUnderstandPlaintext,
+	}, nil
+}
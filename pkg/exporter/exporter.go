@@ -0,0 +1,196 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/aegis"
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+	"github.com/putrasattvika/andotp-cli/pkg/exporter/config"
+	"github.com/putrasattvika/andotp-cli/pkg/googleauth"
+	"github.com/putrasattvika/andotp-cli/pkg/loader"
+	"github.com/putrasattvika/andotp-cli/pkg/lookup"
+	"github.com/putrasattvika/andotp-cli/pkg/qrcode"
+)
+
+// exportFunc serializes OTP keys into an export format. The password is only
+// given to encrypted export formats.
+type exportFunc func(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error)
+
+var (
+	// Mapping between export format name and its serializer
+	exportFormats = map[string]exportFunc{
+		"otpauth":           exportOTPAuth,
+		"otpauth-migration": exportOTPAuthMigration,
+		"aegis":             exportAegis,
+		"aegis-encrypted":   exportAegis,
+		"json":              exportJSON,
+		"csv":               exportCSV,
+		"yaml":              exportYAML,
+	}
+
+	// Export formats encrypted with a password. The other formats hold every
+	// secret in plaintext, which needs an explicit confirmation.
+	encryptedFormats = map[string]bool{
+		"aegis-encrypted": true,
+	}
+
+	// Export formats with one URI per line, which can be shown as QR codes
+	qrFormats = map[string]bool{
+		"otpauth":           true,
+		"otpauth-migration": true,
+	}
+)
+
+// AvailableFormats returns the names of the supported export formats
+func AvailableFormats() []string {
+	formats := make([]string, 0, len(exportFormats))
+	for format := range exportFormats {
+		formats = append(formats, format)
+	}
+
+	sort.Strings(formats)
+
+	return formats
+}
+
+// Exporter writes the OTP keys of a backup in another format
+type Exporter struct {
+	config *config.Config
+}
+
+// Create a new Exporter
+func NewExporter(config *config.Config) (*Exporter, error) {
+	if _, ok := exportFormats[config.Format]; !ok {
+		return nil, fmt.Errorf(
+			"unsupported export format '%s', supported formats: %s",
+			config.Format, strings.Join(AvailableFormats(), ", "),
+		)
+	}
+
+	if !encryptedFormats[config.Format] && !config.IUnderstandPlaintext {
+		return nil, fmt.Errorf(
+			"export format '%s' writes every secret in plaintext, pass --i-understand-plaintext to confirm",
+			config.Format,
+		)
+	}
+
+	if config.QR && !qrFormats[config.Format] {
+		return nil, fmt.Errorf("export format '%s' cannot be shown as QR codes", config.Format)
+	}
+
+	return &Exporter{config: config}, nil
+}
+
+// Export loads the backup and writes its OTP keys in the configured format
+func (e *Exporter) Export() error {
+	loader_, err := loader.NewLoader(e.config.Loader)
+	if err != nil {
+		return errors.Wrap(err, "unable to create backup loader")
+	}
+
+	backup, err := loader_.Load()
+	if err != nil {
+		return errors.Wrap(err, "unable to load OTP keys from andOTP backup file")
+	}
+
+	otpKeys := lookup.FilterByTags(backup.OTPKeys, e.config.Tags)
+
+	var passwordBuf *memguard.LockedBuffer
+
+	if encryptedFormats[e.config.Format] {
+		if passwordBuf, err = e.config.PasswordSource.Read(); err != nil {
+			return errors.Wrap(err, "unable to read export password")
+		}
+
+		defer passwordBuf.Destroy()
+	}
+
+	exported, err := exportFormats[e.config.Format](otpKeys, passwordBuf)
+	if err != nil {
+		return errors.Wrapf(err, "unable to export OTP keys as %s", e.config.Format)
+	}
+
+	defer memguardcore.Wipe(exported)
+
+	if e.config.QR {
+		if exported, err = renderQR(exported); err != nil {
+			return err
+		}
+	}
+
+	if e.config.Output == "" {
+		if _, err := os.Stdout.Write(exported); err != nil {
+			return errors.Wrap(err, "unable to write exported OTP keys to stdout")
+		}
+	} else {
+		if err := ioutil.WriteFile(e.config.Output, exported, 0600); err != nil {
+			return errors.Wrap(err, "unable to write exported OTP keys")
+		}
+	}
+
+	log.Printf("Exported %d OTP keys as %s", len(otpKeys), e.config.Format)
+
+	return nil
+}
+
+// exportOTPAuth exports OTP keys as otpauth:// URIs, one per line
+func exportOTPAuth(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	var out bytes.Buffer
+
+	for _, otpKey := range otpKeys {
+		uri, err := otpKey.URI()
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to export OTP key '%s:%s'", otpKey.Issuer, otpKey.Label)
+		}
+
+		out.WriteString(uri)
+		out.WriteString("\n")
+	}
+
+	return out.Bytes(), nil
+}
+
+// exportOTPAuthMigration exports OTP keys as Google Authenticator's
+// otpauth-migration:// URIs, one per line, each containing a batch of keys
+func exportOTPAuthMigration(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	uris, err := googleauth.MigrationURIsFromOTPKeys(otpKeys, googleauth.DefaultBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(uris, "\n") + "\n"), nil
+}
+
+// exportAegis exports OTP keys as an Aegis vault, encrypted if a password is
+// given
+func exportAegis(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	return aegis.Encode(otpKeys, password)
+}
+
+// renderQR renders each line of the exported URIs as a terminal QR code
+func renderQR(exported []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	lines := strings.Split(strings.TrimSpace(string(exported)), "\n")
+
+	for idx, line := range lines {
+		rendered, err := qrcode.RenderTerminal(line, false)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&out, "QR code %d/%d\n%s\n", idx+1, len(lines), rendered)
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,146 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// Columns of the CSV export, tags are joined with ';'. Either period or
+// counter is empty, depending on the OTP type.
+var csvHeader = []string{
+	"issuer", "label", "type", "algorithm", "digits", "period", "counter", "tags", "secret",
+}
+
+// First characters of CSV cells that spreadsheets evaluate as a formula
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// plaintextKey is an OTP key in the CSV and YAML exports
+type plaintextKey struct {
+	Issuer    string   `yaml:"issuer"`
+	Label     string   `yaml:"label"`
+	Type      string   `yaml:"type"`
+	Algorithm string   `yaml:"algorithm"`
+	Digits    int      `yaml:"digits"`
+	Period    *int     `yaml:"period,omitempty"`
+	Counter   *int64   `yaml:"counter,omitempty"`
+	Tags      []string `yaml:"tags,flow"`
+	Secret    string   `yaml:"secret"`
+}
+
+// newPlaintextKey converts an OTP key, reading its secret from its memguard
+// enclave
+func newPlaintextKey(otpKey *otp.OTPKey) plaintextKey {
+	secretBuf := otpKey.SecretBuffer()
+	defer secretBuf.Destroy()
+
+	key := plaintextKey{
+		Issuer:    otpKey.Issuer,
+		Label:     otpKey.Label,
+		Type:      otpKey.OTPType,
+		Algorithm: otpKey.AlgorithmStr,
+		Digits:    otpKey.DigitsInt,
+		Tags:      otpKey.Tags,
+		Secret:    string(secretBuf.Bytes()),
+	}
+
+	// Only one of period and counter is set, depending on the OTP type
+	if otpKey.OTPType == "HOTP" {
+		counter := otpKey.Counter
+		key.Counter = &counter
+	} else {
+		period := otpKey.Period
+		key.Period = &period
+	}
+
+	if key.Tags == nil {
+		key.Tags = []string{}
+	}
+
+	return key
+}
+
+// exportJSON exports OTP keys as an andOTP plaintext JSON backup
+func exportJSON(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	return otp.OTPKeysToJSON(otpKeys)
+}
+
+// exportCSV exports OTP keys as CSV with a header row
+func exportCSV(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	var out bytes.Buffer
+
+	w := csv.NewWriter(&out)
+
+	if err := w.Write(csvHeader); err != nil {
+		return nil, errors.Wrap(err, "unable to write CSV header")
+	}
+
+	for _, otpKey := range otpKeys {
+		key := newPlaintextKey(otpKey)
+
+		record := []string{
+			csvText(key.Issuer),
+			csvText(key.Label),
+			key.Type,
+			key.Algorithm,
+			strconv.Itoa(key.Digits),
+			"",
+			"",
+			csvText(strings.Join(key.Tags, ";")),
+			key.Secret,
+		}
+
+		if key.Period != nil {
+			record[5] = strconv.Itoa(*key.Period)
+		}
+
+		if key.Counter != nil {
+			record[6] = strconv.FormatInt(*key.Counter, 10)
+		}
+
+		if err := w.Write(record); err != nil {
+			return nil, errors.Wrapf(err, "unable to export OTP key '%s:%s'", key.Issuer, key.Label)
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return nil, errors.Wrap(err, "unable to write CSV")
+	}
+
+	return out.Bytes(), nil
+}
+
+// csvText escapes a text cell which would be evaluated as a formula when the
+// CSV is opened in a spreadsheet, e.g. an issuer of an imported QR code set to
+// "=HYPERLINK(...)", by prefixing it with a quote like spreadsheets do
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// exportYAML exports OTP keys as a YAML list
+func exportYAML(otpKeys []*otp.OTPKey, password *memguard.LockedBuffer) ([]byte, error) {
+	keys := make([]plaintextKey, 0, len(otpKeys))
+	for _, otpKey := range otpKeys {
+		keys = append(keys, newPlaintextKey(otpKey))
+	}
+
+	out, err := yaml.Marshal(keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal YAML")
+	}
+
+	return out, nil
+}
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+func newTestKey(issuer, label, otpType string, period int, counter int64, tags []string) *otp.OTPKey {
+	otpKey := &otp.OTPKey{
+		Issuer:       issuer,
+		Label:        label,
+		OTPType:      otpType,
+		AlgorithmStr: "SHA1",
+		DigitsInt:    6,
+		Period:       period,
+		Counter:      counter,
+		Tags:         tags,
+	}
+	otpKey.SetSecret([]byte("JBSWY3DPEHPK3PXP"))
+
+	return otpKey
+}
+
+func plaintextTestKeys() []*otp.OTPKey {
+	return []*otp.OTPKey{
+		newTestKey("GitHub", "me@example.com", "TOTP", 30, 0, []string{"work", "dev"}),
+		newTestKey("Bank", "token", "HOTP", 0, 42, nil),
+		newTestKey("=HYPERLINK(\"http://evil\")", "+1 555", "TOTP", 60, 0, []string{"-x", "@y"}),
+		newTestKey("Steam", "-", "STEAM", 30, 0, []string{}),
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	out, err := exportCSV(plaintextTestKeys(), nil)
+	if err != nil {
+		t.Fatalf("exportCSV() error = %v", err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV %q: %v", out, err)
+	}
+
+	want := [][]string{
+		csvHeader,
+		{"GitHub", "me@example.com", "TOTP", "SHA1", "6", "30", "", "work;dev", "JBSWY3DPEHPK3PXP"},
+		{"Bank", "token", "HOTP", "SHA1", "6", "", "42", "", "JBSWY3DPEHPK3PXP"},
+		{"'=HYPERLINK(\"http://evil\")", "'+1 555", "TOTP", "SHA1", "6", "60", "", "'-x;@y", "JBSWY3DPEHPK3PXP"},
+		{"Steam", "'-", "STEAM", "SHA1", "6", "30", "", "", "JBSWY3DPEHPK3PXP"},
+	}
+
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("exportCSV() = %q, want %q", records, want)
+	}
+}
+
+func TestExportYAML(t *testing.T) {
+	out, err := exportYAML(plaintextTestKeys(), nil)
+	if err != nil {
+		t.Fatalf("exportYAML() error = %v", err)
+	}
+
+	var got []map[string]interface{}
+	if err := yaml.Unmarshal(out, &got); err != nil {
+		t.Fatalf("invalid YAML %q: %v", out, err)
+	}
+
+	key := func(issuer, label, otpType string, periodOrCounter string, value int, tags ...interface{}) map[string]interface{} {
+		if tags == nil {
+			tags = []interface{}{}
+		}
+
+		return map[string]interface{}{
+			"issuer": issuer, "label": label, "type": otpType, "algorithm": "SHA1", "digits": 6,
+			periodOrCounter: value, "tags": tags, "secret": "JBSWY3DPEHPK3PXP",
+		}
+	}
+
+	// YAML values are exported as-is, only the period or the counter is set
+	want := []map[string]interface{}{
+		key("GitHub", "me@example.com", "TOTP", "period", 30, "work", "dev"),
+		key("Bank", "token", "HOTP", "counter", 42),
+		key("=HYPERLINK(\"http://evil\")", "+1 555", "TOTP", "period", 60, "-x", "@y"),
+		key("Steam", "-", "STEAM", "period", 30),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exportYAML() = %v, want %v", got, want)
+	}
+}
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: "GitHub", want: "GitHub"},
+		{value: "a=b", want: "a=b"},
+		{value: "=1+1", want: "'=1+1"},
+		{value: "+1", want: "'+1"},
+		{value: "-1", want: "'-1"},
+		{value: "@SUM(A1)", want: "'@SUM(A1)"},
+		{value: "\t=1", want: "'\t=1"},
+		{value: "'quoted", want: "'quoted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := csvText(tt.value); got != tt.want {
+				t.Errorf("csvText(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
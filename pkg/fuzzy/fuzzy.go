@@ -0,0 +1,132 @@
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scoring of a match. Every matched character scores, matches right after a
+// previous match or at the start of a word score more, and skipped characters
+// between matches cost a little.
+const (
+	scoreMatch       = 16
+	bonusConsecutive = 12
+	bonusWordStart   = 10
+	bonusTextStart   = 8
+	bonusExact       = 50
+	penaltyGap       = 1
+	maxGapPenalty    = 8
+)
+
+// Score returns how well the pattern matches the text, compared
+// case-insensitively. The pattern matches if all its characters appear in the
+// text in order, e.g. "ghb" matches "GitHub". Returns false if the pattern
+// doesn't match. An empty pattern matches everything with a score of 0.
+func Score(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+
+	if len(p) == 0 {
+		return 0, true
+	}
+
+	if len(p) > len(t) {
+		return 0, false
+	}
+
+	best := -1
+
+	// Try every occurrence of the first character as the start of the match,
+	// the greedy match from an earlier start isn't always the best one
+	for start := 0; start <= len(t)-len(p); start++ {
+		if t[start] != p[0] {
+			continue
+		}
+
+		if score, ok := scoreFrom(p, t, start); ok && score > best {
+			best = score
+		}
+	}
+
+	if best < 0 {
+		return 0, false
+	}
+
+	if len(p) == len(t) && string(p) == string(t) {
+		best += bonusExact
+	}
+
+	return best, true
+}
+
+// scoreFrom greedily matches the pattern in the text starting at the given
+// position, preferring consecutive characters and word starts
+func scoreFrom(p, t []rune, start int) (int, bool) {
+	score := 0
+	prev := -1
+	pos := start
+
+	for _, r := range p {
+		matched := -1
+
+		for i := pos; i < len(t); i++ {
+			if t[i] != r {
+				continue
+			}
+
+			// Take the first occurrence, unless a later one is consecutive or
+			// at a word start
+			if matched < 0 {
+				matched = i
+			}
+
+			if i == prev+1 || isWordStart(t, i) {
+				matched = i
+				break
+			}
+		}
+
+		if matched < 0 {
+			return 0, false
+		}
+
+		score += scoreMatch
+
+		switch {
+		case prev >= 0 && matched == prev+1:
+			score += bonusConsecutive
+		case isWordStart(t, matched):
+			score += bonusWordStart
+		}
+
+		if matched == 0 {
+			score += bonusTextStart
+		}
+
+		if prev >= 0 {
+			gap := (matched - prev - 1) * penaltyGap
+			if gap > maxGapPenalty {
+				gap = maxGapPenalty
+			}
+
+			score -= gap
+		}
+
+		prev = matched
+		pos = matched + 1
+	}
+
+	return score, true
+}
+
+// isWordStart returns true if the character at i starts a word, i.e. it's the
+// first character or follows a separator
+func isWordStart(t []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	prev := t[i-1]
+
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+}
@@ -0,0 +1,91 @@
+package fuzzy
+
+import "testing"
+
+func TestScoreMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		text    string
+		want    bool
+	}{
+		{name: "empty pattern", pattern: "", text: "GitHub", want: true},
+		{name: "empty pattern and text", pattern: "", text: "", want: true},
+		{name: "exact", pattern: "github", text: "github", want: true},
+		{name: "case-insensitive", pattern: "GITHUB", text: "GitHub", want: true},
+		{name: "subsequence", pattern: "ghb", text: "GitHub", want: true},
+		{name: "across words", pattern: "gme", text: "GitHub: me@example.com", want: true},
+		{name: "non-ASCII", pattern: "zur", text: "Zürich Bank", want: false},
+		{name: "non-ASCII subsequence", pattern: "zür", text: "Zürich Bank", want: true},
+		{name: "out of order", pattern: "bhg", text: "GitHub", want: false},
+		{name: "missing character", pattern: "gitlab", text: "GitHub", want: false},
+		{name: "longer pattern", pattern: "githubs", text: "github", want: false},
+		{name: "empty text", pattern: "g", text: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, got := Score(tt.pattern, tt.text)
+
+			if got != tt.want {
+				t.Errorf("Score(%q, %q) matched = %v, want %v", tt.pattern, tt.text, got, tt.want)
+			}
+
+			if tt.pattern == "" && score != 0 {
+				t.Errorf("Score(%q, %q) = %d, want 0", tt.pattern, tt.text, score)
+			}
+		})
+	}
+}
+
+func TestScoreOrdering(t *testing.T) {
+	// Each text scores strictly higher than the next one for the pattern
+	tests := []struct {
+		name    string
+		pattern string
+		texts   []string
+	}{
+		{
+			name:    "exact, prefix, word start, scattered",
+			pattern: "git",
+			texts:   []string{"git", "github", "my git server", "digital"},
+		},
+		{
+			name:    "consecutive over scattered",
+			pattern: "hub",
+			texts:   []string{"hub", "github", "hxuxb", "hxxxxxuxxxxxb"},
+		},
+		{
+			name:    "word starts over gaps",
+			pattern: "gh",
+			texts:   []string{"gh", "git hub", "github", "gaaaaaaaaaaaaah"},
+		},
+		{
+			name:    "later start with a better match",
+			pattern: "bank",
+			texts:   []string{"Bank", "my bank", "bbank", "bxaxnxk"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := 0
+
+			for idx, text := range tt.texts {
+				score, ok := Score(tt.pattern, text)
+				if !ok {
+					t.Fatalf("Score(%q, %q) didn't match", tt.pattern, text)
+				}
+
+				if idx > 0 && score >= prev {
+					t.Errorf(
+						"Score(%q, %q) = %d, want less than %d of %q",
+						tt.pattern, text, score, prev, tt.texts[idx-1],
+					)
+				}
+
+				prev = score
+			}
+		})
+	}
+}
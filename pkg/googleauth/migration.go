@@ -0,0 +1,452 @@
+package googleauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// DefaultBatchSize is the default number of OTP keys in a single migration
+// URI, small enough for the URI's QR code to be scannable
+const DefaultBatchSize = 10
+
+// Google Authenticator only supports 30 seconds TOTP
+const migrationPeriod = 30
+
+// Field numbers and enum values of Google Authenticator's migration payload:
+//
+//	message MigrationPayload {
+//	  enum Algorithm { ALGORITHM_UNSPECIFIED = 0; SHA1 = 1; SHA256 = 2; SHA512 = 3; MD5 = 4; }
+//	  enum DigitCount { DIGIT_COUNT_UNSPECIFIED = 0; SIX = 1; EIGHT = 2; }
+//	  enum OtpType { OTP_TYPE_UNSPECIFIED = 0; HOTP = 1; TOTP = 2; }
+//
+//	  message OtpParameters {
+//	    bytes secret = 1;
+//	    string name = 2;
+//	    string issuer = 3;
+//	    Algorithm algorithm = 4;
+//	    DigitCount digits = 5;
+//	    OtpType type = 6;
+//	    int64 counter = 7;
+//	  }
+//
+//	  repeated OtpParameters otp_parameters = 1;
+//	  int32 version = 2;
+//	  int32 batch_size = 3;
+//	  int32 batch_index = 4;
+//	  int32 batch_id = 5;
+//	}
+const (
+	payloadFieldOTPParameters = 1
+	payloadFieldVersion       = 2
+	payloadFieldBatchSize     = 3
+	payloadFieldBatchIndex    = 4
+	payloadFieldBatchID       = 5
+
+	paramsFieldSecret    = 1
+	paramsFieldName      = 2
+	paramsFieldIssuer    = 3
+	paramsFieldAlgorithm = 4
+	paramsFieldDigits    = 5
+	paramsFieldType      = 6
+	paramsFieldCounter   = 7
+
+	payloadVersion = 1
+)
+
+var migrationAlgorithmMapping = map[uint64]string{
+	0: "SHA1",
+	1: "SHA1",
+	2: "SHA256",
+	3: "SHA512",
+	4: "MD5",
+}
+
+var migrationDigitsMapping = map[uint64]int{
+	0: 6,
+	1: 6,
+	2: 8,
+}
+
+var migrationTypeMapping = map[uint64]string{
+	0: "TOTP",
+	1: "HOTP",
+	2: "TOTP",
+}
+
+// migrationPayload is a decoded migration payload
+type migrationPayload struct {
+	otpKeys    []*otp.OTPKey
+	batchSize  int
+	batchIndex int
+	batchID    int
+}
+
+// OTPKeysFromMigrationURIs decodes OTP keys from Google Authenticator's
+// "Transfer accounts" otpauth-migration://offline?data=... URIs. URIs of a
+// multi-QR export should be given together, a warning is logged if some URIs
+// of a batch are missing.
+func OTPKeysFromMigrationURIs(uris []string) ([]*otp.OTPKey, error) {
+	otpKeys := []*otp.OTPKey{}
+
+	// Batch ID -> batch indexes seen, and the batch's size
+	batchIndexes := make(map[int]map[int]bool)
+	batchSizes := make(map[int]int)
+
+	for _, uri := range uris {
+		payload, err := decodeMigrationURI(uri)
+		if err != nil {
+			return nil, err
+		}
+
+		otpKeys = append(otpKeys, payload.otpKeys...)
+
+		if _, ok := batchIndexes[payload.batchID]; !ok {
+			batchIndexes[payload.batchID] = make(map[int]bool)
+		}
+
+		batchIndexes[payload.batchID][payload.batchIndex] = true
+		batchSizes[payload.batchID] = payload.batchSize
+	}
+
+	for batchID, indexes := range batchIndexes {
+		if len(indexes) < batchSizes[batchID] {
+			log.Printf(
+				"Only %d of %d migration QR codes of batch %d were given, some OTP keys may be missing",
+				len(indexes), batchSizes[batchID], batchID,
+			)
+		}
+	}
+
+	return otpKeys, nil
+}
+
+// MigrationURIsFromOTPKeys encodes OTP keys into Google Authenticator's
+// otpauth-migration://offline?data=... URIs, each containing at most batchSize
+// keys. OTP keys that can't be represented in Google Authenticator (e.g. Steam
+// keys or TOTP keys with a non-30 seconds period) are skipped with a warning.
+//
+// The returned URIs contain the plaintext secrets.
+func MigrationURIsFromOTPKeys(otpKeys []*otp.OTPKey, batchSize int) ([]string, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	encodedParams := [][]byte{}
+
+	for _, otpKey := range otpKeys {
+		params, err := encodeOTPParameters(otpKey)
+		if err != nil {
+			log.Printf("Skipping OTP key '%s:%s': %v", otpKey.Issuer, otpKey.Label, err)
+			continue
+		}
+
+		encodedParams = append(encodedParams, params)
+	}
+
+	batchIDBytes := make([]byte, 4)
+	if _, err := rand.Read(batchIDBytes); err != nil {
+		return nil, errors.Wrap(err, "error generating migration batch ID")
+	}
+
+	batchID := int32(binary.BigEndian.Uint32(batchIDBytes) & 0x7fffffff)
+	batchCount := (len(encodedParams) + batchSize - 1) / batchSize
+
+	uris := make([]string, 0, batchCount)
+
+	for batchIndex := 0; batchIndex < batchCount; batchIndex++ {
+		end := (batchIndex + 1) * batchSize
+		if end > len(encodedParams) {
+			end = len(encodedParams)
+		}
+
+		payload := []byte{}
+
+		for _, params := range encodedParams[batchIndex*batchSize : end] {
+			payload = protowire.AppendTag(payload, payloadFieldOTPParameters, protowire.BytesType)
+			payload = protowire.AppendBytes(payload, params)
+		}
+
+		for _, field := range []struct {
+			num   protowire.Number
+			value int32
+		}{
+			{payloadFieldVersion, payloadVersion},
+			{payloadFieldBatchSize, int32(batchCount)},
+			{payloadFieldBatchIndex, int32(batchIndex)},
+			{payloadFieldBatchID, batchID},
+		} {
+			payload = protowire.AppendTag(payload, field.num, protowire.VarintType)
+			payload = protowire.AppendVarint(payload, uint64(field.value))
+		}
+
+		uris = append(
+			uris,
+			"otpauth-migration://offline?data="+url.QueryEscape(base64.StdEncoding.EncodeToString(payload)),
+		)
+
+		memguardcore.Wipe(payload)
+	}
+
+	for _, params := range encodedParams {
+		memguardcore.Wipe(params)
+	}
+
+	return uris, nil
+}
+
+// decodeMigrationURI decodes a single otpauth-migration:// URI
+func decodeMigrationURI(uri string) (*migrationPayload, error) {
+	parsedURI, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid otpauth-migration URI")
+	}
+
+	if parsedURI.Scheme != "otpauth-migration" {
+		return nil, fmt.Errorf("invalid otpauth-migration URI scheme '%s'", parsedURI.Scheme)
+	}
+
+	// The data is standard base64, whose '+' characters may or may not have
+	// been URL-encoded, so read it from the raw query instead
+	data := ""
+	for _, param := range strings.Split(parsedURI.RawQuery, "&") {
+		if strings.HasPrefix(param, "data=") {
+			data, err = url.PathUnescape(strings.TrimPrefix(param, "data="))
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid otpauth-migration URI data")
+			}
+		}
+	}
+
+	payloadBytes, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		payloadBytes, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid otpauth-migration URI data")
+	}
+
+	defer memguardcore.Wipe(payloadBytes)
+
+	payload := &migrationPayload{batchSize: 1}
+
+	err = consumeFields(payloadBytes, func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error {
+		switch {
+		case num == payloadFieldOTPParameters && typ == protowire.BytesType:
+			otpKey, err := decodeOTPParameters(value)
+			if err != nil {
+				return err
+			}
+
+			payload.otpKeys = append(payload.otpKeys, otpKey)
+
+		case num == payloadFieldBatchSize && typ == protowire.VarintType:
+			payload.batchSize = int(varint)
+
+		case num == payloadFieldBatchIndex && typ == protowire.VarintType:
+			payload.batchIndex = int(varint)
+
+		case num == payloadFieldBatchID && typ == protowire.VarintType:
+			payload.batchID = int(varint)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid otpauth-migration payload")
+	}
+
+	return payload, nil
+}
+
+// decodeOTPParameters decodes an OtpParameters message into an OTP key
+func decodeOTPParameters(params []byte) (*otp.OTPKey, error) {
+	otpKey := &otp.OTPKey{
+		OTPType:      "TOTP",
+		AlgorithmStr: "SHA1",
+		DigitsInt:    6,
+		Period:       migrationPeriod,
+		Tags:         []string{},
+		Thumbnail:    "Default",
+	}
+
+	var secret []byte
+
+	err := consumeFields(params, func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error {
+		var ok bool
+
+		switch {
+		case num == paramsFieldSecret && typ == protowire.BytesType:
+			secret = append([]byte{}, value...)
+
+		case num == paramsFieldName && typ == protowire.BytesType:
+			otpKey.Label = string(value)
+
+		case num == paramsFieldIssuer && typ == protowire.BytesType:
+			otpKey.Issuer = string(value)
+
+		case num == paramsFieldAlgorithm && typ == protowire.VarintType:
+			if otpKey.AlgorithmStr, ok = migrationAlgorithmMapping[varint]; !ok {
+				return fmt.Errorf("unsupported algorithm %d", varint)
+			}
+
+		case num == paramsFieldDigits && typ == protowire.VarintType:
+			if otpKey.DigitsInt, ok = migrationDigitsMapping[varint]; !ok {
+				return fmt.Errorf("unsupported digit count %d", varint)
+			}
+
+		case num == paramsFieldType && typ == protowire.VarintType:
+			if otpKey.OTPType, ok = migrationTypeMapping[varint]; !ok {
+				return fmt.Errorf("unsupported OTP type %d", varint)
+			}
+
+		case num == paramsFieldCounter && typ == protowire.VarintType:
+			otpKey.Counter = int64(varint)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Google Authenticator prefixes the name with the issuer
+	if otpKey.Issuer != "" {
+		otpKey.Label = strings.TrimPrefix(otpKey.Label, otpKey.Issuer+":")
+	}
+
+	// Wipes secret
+	otpKey.SetDecodedSecret(secret)
+
+	if err := otpKey.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid OTP key '%s:%s'", otpKey.Issuer, otpKey.Label)
+	}
+
+	return otpKey, nil
+}
+
+// encodeOTPParameters encodes an OTP key into an OtpParameters message
+func encodeOTPParameters(otpKey *otp.OTPKey) ([]byte, error) {
+	var otpType, algorithm, digits uint64
+
+	switch otpKey.OTPType {
+	case "TOTP":
+		otpType = 2
+		if otpKey.Period != migrationPeriod {
+			return nil, fmt.Errorf("unsupported TOTP period %d", otpKey.Period)
+		}
+	case "HOTP":
+		otpType = 1
+	default:
+		return nil, fmt.Errorf("unsupported OTP type '%s'", otpKey.OTPType)
+	}
+
+	for value, name := range migrationAlgorithmMapping {
+		if value != 0 && name == otpKey.AlgorithmStr {
+			algorithm = value
+		}
+	}
+
+	for value, count := range migrationDigitsMapping {
+		if value != 0 && count == otpKey.DigitsInt {
+			digits = value
+		}
+	}
+
+	if algorithm == 0 {
+		return nil, fmt.Errorf("unsupported algorithm '%s'", otpKey.AlgorithmStr)
+	}
+
+	if digits == 0 {
+		return nil, fmt.Errorf("unsupported digits %d", otpKey.DigitsInt)
+	}
+
+	secretBuf, err := otpKey.DecodedSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	defer secretBuf.Destroy()
+
+	name := otpKey.Label
+	if otpKey.Issuer != "" {
+		name = otpKey.Issuer + ":" + otpKey.Label
+	}
+
+	params := []byte{}
+	params = protowire.AppendTag(params, paramsFieldSecret, protowire.BytesType)
+	params = protowire.AppendBytes(params, secretBuf.Bytes())
+	params = protowire.AppendTag(params, paramsFieldName, protowire.BytesType)
+	params = protowire.AppendString(params, name)
+	params = protowire.AppendTag(params, paramsFieldIssuer, protowire.BytesType)
+	params = protowire.AppendString(params, otpKey.Issuer)
+
+	for _, field := range []struct {
+		num   protowire.Number
+		value uint64
+	}{
+		{paramsFieldAlgorithm, algorithm},
+		{paramsFieldDigits, digits},
+		{paramsFieldType, otpType},
+		{paramsFieldCounter, uint64(otpKey.Counter)},
+	} {
+		params = protowire.AppendTag(params, field.num, protowire.VarintType)
+		params = protowire.AppendVarint(params, field.value)
+	}
+
+	return params, nil
+}
+
+// consumeFields iterates over the fields of a protobuf message. For bytes
+// fields value is set, for varint fields varint is set. Other field types are
+// skipped.
+func consumeFields(
+	message []byte,
+	fn func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error,
+) error {
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		message = message[n:]
+
+		var value []byte
+		var varint uint64
+
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(message)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(message)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, message)
+		}
+
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		message = message[n:]
+
+		if typ == protowire.BytesType || typ == protowire.VarintType {
+			if err := fn(num, typ, value, varint); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package googleauth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// keyFields are the fields of an OTP key carried by migration URIs, with its
+// plaintext secret
+type keyFields struct {
+	Issuer    string
+	Label     string
+	OTPType   string
+	Algorithm string
+	Digits    int
+	Counter   int64
+	Secret    string
+}
+
+func fieldsOf(k *otp.OTPKey) keyFields {
+	secretBuf := k.SecretBuffer()
+	defer secretBuf.Destroy()
+
+	fields := keyFields{
+		Issuer:    k.Issuer,
+		Label:     k.Label,
+		OTPType:   k.OTPType,
+		Algorithm: k.AlgorithmStr,
+		Digits:    k.DigitsInt,
+		Secret:    string(secretBuf.Bytes()),
+	}
+
+	if k.OTPType == "HOTP" {
+		fields.Counter = k.Counter
+	}
+
+	return fields
+}
+
+func newTestKey(t *testing.T, fields keyFields, period int) *otp.OTPKey {
+	t.Helper()
+
+	otpKey := &otp.OTPKey{
+		Issuer:       fields.Issuer,
+		Label:        fields.Label,
+		OTPType:      fields.OTPType,
+		AlgorithmStr: fields.Algorithm,
+		DigitsInt:    fields.Digits,
+		Period:       period,
+		Counter:      fields.Counter,
+	}
+	otpKey.SetSecret([]byte(fields.Secret))
+
+	if err := otpKey.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	return otpKey
+}
+
+var migrationTestKeys = []keyFields{
+	{Issuer: "GitHub", Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Secret: "JBSWY3DPEHPK3PXP"},
+	{Issuer: "Acme Co", Label: "a@b.c", OTPType: "TOTP", Algorithm: "SHA256", Digits: 8, Secret: "GEZDGNBVGY3TQOJQ"},
+	{Label: "no issuer", OTPType: "TOTP", Algorithm: "SHA512", Digits: 6, Secret: "MFRGGZDFMZTWQ2LK"},
+	{Issuer: "Legacy", Label: "md5", OTPType: "TOTP", Algorithm: "MD5", Digits: 8, Secret: "ONSWG4TFOQ"},
+	{Issuer: "Bank", Label: "hotp", OTPType: "HOTP", Algorithm: "SHA1", Digits: 6, Counter: 42, Secret: "KRSXG5CTMVRXEZLU"},
+}
+
+func TestMigrationURIsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		wantURIs  int
+	}{
+		{name: "one key per URI", batchSize: 1, wantURIs: 5},
+		{name: "two keys per URI", batchSize: 2, wantURIs: 3},
+		{name: "all keys in one URI", batchSize: 10, wantURIs: 1},
+		{name: "default batch size", batchSize: 0, wantURIs: 1},
+	}
+
+	otpKeys := []*otp.OTPKey{}
+	for _, fields := range migrationTestKeys {
+		otpKeys = append(otpKeys, newTestKey(t, fields, 30))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uris, err := MigrationURIsFromOTPKeys(otpKeys, tt.batchSize)
+			if err != nil {
+				t.Fatalf("MigrationURIsFromOTPKeys() error = %v", err)
+			}
+
+			if len(uris) != tt.wantURIs {
+				t.Fatalf("len(MigrationURIsFromOTPKeys()) = %d, want %d", len(uris), tt.wantURIs)
+			}
+
+			var batchID int
+
+			for idx, uri := range uris {
+				payload, err := decodeMigrationURI(uri)
+				if err != nil {
+					t.Fatalf("decodeMigrationURI(%s) error = %v", uri, err)
+				}
+
+				if idx == 0 {
+					batchID = payload.batchID
+				}
+
+				if payload.batchID != batchID || payload.batchIndex != idx || payload.batchSize != len(uris) {
+					t.Errorf(
+						"batch of URI %d = id %d index %d size %d, want id %d index %d size %d",
+						idx, payload.batchID, payload.batchIndex, payload.batchSize, batchID, idx, len(uris),
+					)
+				}
+			}
+
+			decoded, err := OTPKeysFromMigrationURIs(uris)
+			if err != nil {
+				t.Fatalf("OTPKeysFromMigrationURIs() error = %v", err)
+			}
+
+			got := []keyFields{}
+			for _, otpKey := range decoded {
+				got = append(got, fieldsOf(otpKey))
+			}
+
+			if !reflect.DeepEqual(got, migrationTestKeys) {
+				t.Errorf("round trip = %+v, want %+v", got, migrationTestKeys)
+			}
+		})
+	}
+}
+
+func TestMigrationURIsSkipUnsupported(t *testing.T) {
+	supported := keyFields{
+		Issuer: "GitHub", Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Secret: "JBSWY3DPEHPK3PXP",
+	}
+
+	tests := []struct {
+		name   string
+		fields keyFields
+		period int
+	}{
+		{
+			name:   "steam",
+			fields: keyFields{Issuer: "Steam", Label: "me", OTPType: "STEAM", Algorithm: "SHA1", Digits: 5, Secret: "JBSWY3DPEHPK3PXP"},
+			period: 30,
+		},
+		{
+			name:   "period",
+			fields: keyFields{Issuer: "Slow", Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Secret: "JBSWY3DPEHPK3PXP"},
+			period: 60,
+		},
+		{
+			name:   "digits",
+			fields: keyFields{Issuer: "Odd", Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 7, Secret: "JBSWY3DPEHPK3PXP"},
+			period: 30,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otpKeys := []*otp.OTPKey{newTestKey(t, tt.fields, tt.period), newTestKey(t, supported, 30)}
+
+			uris, err := MigrationURIsFromOTPKeys(otpKeys, 1)
+			if err != nil {
+				t.Fatalf("MigrationURIsFromOTPKeys() error = %v", err)
+			}
+
+			if len(uris) != 1 {
+				t.Fatalf("len(MigrationURIsFromOTPKeys()) = %d, want 1", len(uris))
+			}
+
+			decoded, err := OTPKeysFromMigrationURIs(uris)
+			if err != nil {
+				t.Fatalf("OTPKeysFromMigrationURIs() error = %v", err)
+			}
+
+			if len(decoded) != 1 || !reflect.DeepEqual(fieldsOf(decoded[0]), supported) {
+				t.Errorf("OTPKeysFromMigrationURIs() decoded %d keys, want only %+v", len(decoded), supported)
+			}
+		})
+	}
+}
+
+func TestDecodeMigrationURIData(t *testing.T) {
+	otpKeys := []*otp.OTPKey{newTestKey(t, migrationTestKeys[0], 30)}
+
+	uris, err := MigrationURIsFromOTPKeys(otpKeys, 1)
+	if err != nil {
+		t.Fatalf("MigrationURIsFromOTPKeys() error = %v", err)
+	}
+
+	payload, err := decodeMigrationURI(uris[0])
+	if err != nil {
+		t.Fatalf("decodeMigrationURI() error = %v", err)
+	}
+
+	// Re-encode the same payload with the data written in different ways
+	data, err := base64.StdEncoding.DecodeString(dataOf(t, uris[0]))
+	if err != nil {
+		t.Fatalf("invalid data in %s: %v", uris[0], err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "escaped", data: strings.NewReplacer("+", "%2B", "/", "%2F", "=", "%3D").Replace(encoded)},
+		{name: "raw plus signs", data: encoded},
+		{name: "unpadded", data: strings.TrimRight(encoded, "=")},
+		{name: "invalid", data: "not%20base64!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeMigrationURI(fmt.Sprintf("otpauth-migration://offline?data=%s", tt.data))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decodeMigrationURI() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("decodeMigrationURI() error = %v", err)
+			}
+
+			if len(got.otpKeys) != 1 || !reflect.DeepEqual(fieldsOf(got.otpKeys[0]), fieldsOf(payload.otpKeys[0])) {
+				t.Errorf("decodeMigrationURI() keys = %+v, want %+v", got.otpKeys, payload.otpKeys)
+			}
+
+			if got.batchID != payload.batchID {
+				t.Errorf("decodeMigrationURI() batch ID = %d, want %d", got.batchID, payload.batchID)
+			}
+		})
+	}
+
+	if _, err := decodeMigrationURI("otpauth://offline?data=" + encoded); err == nil {
+		t.Errorf("decodeMigrationURI() of an otpauth URI succeeded, want an error")
+	}
+}
+
+// dataOf returns the unescaped data parameter of a migration URI
+func dataOf(t *testing.T, uri string) string {
+	t.Helper()
+
+	idx := strings.Index(uri, "data=")
+	if idx < 0 {
+		t.Fatalf("no data in %s", uri)
+	}
+
+	return strings.NewReplacer("%2B", "+", "%2F", "/", "%3D", "=").Replace(uri[idx+len("data="):])
+}
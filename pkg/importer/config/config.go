@@ -0,0 +1,64 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+	loaderconfig "github.com/putrasattvika/andotp-cli/pkg/loader/config"
+	"github.com/putrasattvika/andotp-cli/pkg/password"
+)
+
+// Configuration used to import OTP keys into a backup
+type Config struct {
+	// Configuration for loading the andOTP backup
+	Loader *loaderconfig.Config
+
+	// Format of the imported OTP keys
+	Format string
+
+	// Path to read the imported OTP keys from, stdin if empty
+	Input string
+
+	// Where to read the password of encrypted import formats from
+	PasswordSource password.Source
+
+	// Path to write the modified backup to. The backup file is overwritten if
+	// empty.
+	Output string
+}
+
+func ParseCmdConfig(cmdConfig *cmdconfig.Config, cmdImportConfig *cmdconfig.ImportConfig) (*Config, error) {
+	loaderConfig, err := loaderconfig.ParseCmdConfig(cmdConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// --format
+	if cmdImportConfig.Format == "" {
+		return nil, errors.New("--format cannot be empty")
+	}
+
+	// <input>
+	input := cmdImportConfig.Input
+	if input == "-" {
+		input = ""
+	}
+
+	// --vault-password-source
+	passwordSource, err := password.ParseSource(cmdImportConfig.VaultPasswordSource)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid --vault-password-source")
+	}
+
+	if prompt, ok := passwordSource.(*password.Prompt); ok {
+		prompt.Message = "Enter import password: "
+	}
+
+	return &Config{
+		Loader: loaderConfig,
+		Format: cmdImportConfig.Format,
+		Input:  input,
+		Output: cmdImportConfig.Output,
+
+		PasswordSource: passwordSource,
+	}, nil
+}
@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+
+	andotpbackup "github.com/putrasattvika/andotp-cli/pkg/andotp/backup"
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+	"github.com/putrasattvika/andotp-cli/pkg/importer/config"
+	"github.com/putrasattvika/andotp-cli/pkg/loader"
+)
+
+// FormatAuto detects the import format from the imported content
+const FormatAuto = "auto"
+
+var (
+	// Import format names kept for compatibility, mapped to the backup format
+	// that handles them
+	formatAliases = map[string]string{
+		"otpauth-migration": andotpbackup.FormatOTPAuth,
+	}
+)
+
+// AvailableFormats returns the names of the supported import formats
+func AvailableFormats() []string {
+	formats := []string{FormatAuto}
+	formats = append(formats, andotpbackup.AvailableFormats()...)
+
+	aliases := make([]string, 0, len(formatAliases))
+	for alias := range formatAliases {
+		aliases = append(aliases, alias)
+	}
+
+	sort.Strings(aliases)
+
+	return append(formats, aliases...)
+}
+
+// Importer adds OTP keys in another format to a backup
+type Importer struct {
+	config *config.Config
+
+	// Format of the imported content, nil to detect it
+	format andotpbackup.Format
+}
+
+// Create a new Importer
+func NewImporter(config *config.Config) (*Importer, error) {
+	importer := &Importer{config: config}
+
+	if config.Format == FormatAuto {
+		return importer, nil
+	}
+
+	name := config.Format
+	if alias, ok := formatAliases[name]; ok {
+		name = alias
+	}
+
+	format, err := config.Loader.Formats.ByName(name)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"unsupported import format '%s', supported formats: %s",
+			config.Format, strings.Join(AvailableFormats(), ", "),
+		)
+	}
+
+	importer.format = format
+
+	return importer, nil
+}
+
+// Import parses the OTP keys from the input, adds the ones not yet in the
+// backup, then stores the backup
+func (i *Importer) Import() error {
+	var content []byte
+	var err error
+
+	if i.config.Input == "" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(i.config.Input)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to read OTP keys to import")
+	}
+
+	importedOTPKeys, err := i.decode(content)
+	memguardcore.Wipe(content)
+
+	if err != nil {
+		return errors.Wrap(err, "unable to import OTP keys")
+	}
+
+	loader_, err := loader.NewLoader(i.config.Loader)
+	if err != nil {
+		return errors.Wrap(err, "unable to create backup loader")
+	}
+
+	backup, err := loader_.Load()
+	if err != nil {
+		return errors.Wrap(err, "unable to load OTP keys from andOTP backup file")
+	}
+
+	// Skip OTP keys that already exist in the backup, identified by their
+	// issuer and label
+	existing := make(map[string]bool)
+	for _, otpKey := range backup.OTPKeys {
+		existing[otpKey.Issuer+":"+otpKey.Label] = true
+	}
+
+	added := 0
+
+	for _, otpKey := range importedOTPKeys {
+		if existing[otpKey.Issuer+":"+otpKey.Label] {
+			log.Printf("Skipping OTP key '%s:%s', it already exists in the backup", otpKey.Issuer, otpKey.Label)
+			continue
+		}
+
+		backup.OTPKeys = append(backup.OTPKeys, otpKey)
+		existing[otpKey.Issuer+":"+otpKey.Label] = true
+		added++
+	}
+
+	if added == 0 {
+		log.Print("No new OTP keys to import")
+		return nil
+	}
+
+	if err := loader_.Store(backup, i.config.Output); err != nil {
+		return errors.Wrap(err, "unable to store the modified backup")
+	}
+
+	log.Printf("Imported %d OTP keys", added)
+
+	return nil
+}
+
+// decode parses the OTP keys from the imported content, reading the password
+// from the password source if the content is encrypted
+func (i *Importer) decode(content []byte) ([]*otp.OTPKey, error) {
+	var backup *andotpbackup.Backup
+	var err error
+
+	if i.format == nil {
+		backup, err = andotpbackup.NewBackup(content, i.config.Loader.Formats)
+	} else {
+		backup, err = andotpbackup.NewBackupWithFormat(content, i.format)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode the imported content")
+	}
+
+	if backup.IsEncrypted() {
+		passwordBuf, err := i.config.PasswordSource.Read()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read import password")
+		}
+
+		defer passwordBuf.Destroy()
+
+		if err := backup.Decrypt(passwordBuf); err != nil {
+			return nil, errors.Wrapf(err, "unable to decode %s content", backup.Format())
+		}
+	}
+
+	return backup.OTPKeys, nil
+}
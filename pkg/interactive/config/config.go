@@ -1,34 +1,40 @@
 package config
 
 import (
-	"net/url"
-
 	"github.com/pkg/errors"
 	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/clipboard"
+	loaderconfig "github.com/putrasattvika/andotp-cli/pkg/loader/config"
 )
 
 // Configuration used to start the interactive CLI
 type Config struct {
-	// URI to an andOTP encrypted backup file
-	//
-	// Supports two sources:
-	//   - local file (e.g. file:///home/myuser/otp_accounts.json.aes)
-	//   - KDE connect exposed device filesystem (e.g. kdeconnect://_/device-name-or-id/path/to/otp_accounts.json.aes)
-	BackupFileURI *url.URL
+	// Configuration for loading the andOTP backup
+	Loader *loaderconfig.Config
+
+	// When to clear tokens copied to the clipboard
+	ClipboardClearAfter clipboard.ClearAfter
+
+	// Only show OTP keys having any of these tags, all OTP keys if empty
+	Tags []string
 }
 
-func ParseCmdConfig(cmdConfig *cmdconfig.Config) (*Config, error) {
-	// --backup-file-uri
-	if cmdConfig.BackupFileURI == "" {
-		return nil, errors.New("--backup-file-uri cannot be empty")
+func ParseCmdConfig(cmdConfig *cmdconfig.Config, cmdInteractiveConfig *cmdconfig.InteractiveConfig) (*Config, error) {
+	loaderConfig, err := loaderconfig.ParseCmdConfig(cmdConfig)
+	if err != nil {
+		return nil, err
 	}
 
-	backupFileURI, err := url.Parse(cmdConfig.BackupFileURI)
+	// --clipboard-clear-after
+	clearAfter, err := clipboard.ParseClearAfter(cmdConfig.ClipboardClearAfter)
 	if err != nil {
-		return nil, errors.Wrap(err, "invalid --backup-file-uri")
+		return nil, errors.Wrap(err, "invalid --clipboard-clear-after")
 	}
 
 	return &Config{
-		BackupFileURI: backupFileURI,
+		Loader: loaderConfig,
+		Tags:   cmdInteractiveConfig.Tags,
+
+		ClipboardClearAfter: clearAfter,
 	}, nil
 }
@@ -3,25 +3,27 @@ package interactive
 import (
 	"fmt"
 	"log"
-	"runtime"
+	"sort"
 	"strings"
-	"syscall"
 
-	"github.com/atotto/clipboard"
 	prompt "github.com/c-bata/go-prompt"
 	"github.com/pkg/errors"
-	"golang.org/x/term"
 
 	andotpbackup "github.com/putrasattvika/andotp-cli/pkg/andotp/backup"
-	andotpbackupprovider "github.com/putrasattvika/andotp-cli/pkg/andotp/backupprovider"
 	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+	"github.com/putrasattvika/andotp-cli/pkg/clipboard"
 	"github.com/putrasattvika/andotp-cli/pkg/interactive/config"
+	"github.com/putrasattvika/andotp-cli/pkg/loader"
+	"github.com/putrasattvika/andotp-cli/pkg/lookup"
+	"github.com/putrasattvika/andotp-cli/pkg/usage"
 )
 
 // Struct for the interactive CLI interface
 type Interactive struct {
 	config       *config.Config
+	loader       *loader.Loader
 	andOTPBackup *andotpbackup.Backup
+	usage        *usage.Store
 }
 
 // Create a new Interactive
@@ -40,70 +42,90 @@ func (i *Interactive) Start() error {
 		len(i.andOTPBackup.OTPKeys),
 	)
 
-	log.Print("Starting interactive session. Press ctrl+d to exit.")
+	log.Print(
+		"Starting interactive session. Press ctrl+d to exit. Type #tag to filter by tag, " +
+			commandTags + " to list tags and " + commandGroup + " to list OTP keys by tag.",
+	)
 
 	return i.startInteractiveSession()
 }
 
 func (i *Interactive) loadOTPKeys() error {
-	// Get backup file provider
-	backupProvider, err := andotpbackupprovider.ConstructBackupProvider(i.config.BackupFileURI)
-	if err != nil {
-		return errors.Wrap(err, "unable to construct backup file provider")
-	}
-
-	// Fetch & parse backup contents
-	backupContents, err := backupProvider.FetchBackup()
+	loader_, err := loader.NewLoader(i.config.Loader)
 	if err != nil {
-		return errors.Wrap(err, "unable to fetch backup file")
+		return errors.Wrap(err, "unable to create backup loader")
 	}
 
-	backup, err := andotpbackup.NewBackup(backupContents)
+	backup, err := loader_.Load()
 	if err != nil {
-		return errors.Wrap(err, "unable to parse backup file")
-	}
-
-	// Decrypt the backup
-	if backup.IsEncrypted() {
-		fmt.Print("Enter backup password: ")
-		passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
-		fmt.Print("\n")
-
-		if err != nil {
-			return errors.Wrap(err, "error reading backup password from stdin")
-		}
-
-		if err := backup.Decrypt(string(passwordBytes)); err != nil {
-			return errors.Wrap(err, "unable to decrypt backup")
-		}
+		return err
 	}
 
-	// Run GC to remove decryption password and decrypted backup from memory
-	runtime.GC()
-
+	i.loader = loader_
 	i.andOTPBackup = backup
 
+	i.usage = usage.OpenOrEmpty()
+
 	return nil
 }
 
+// Commands of the interactive shell
+const (
+	commandTags  = ":tags"
+	commandGroup = ":group"
+)
+
 func (i *Interactive) startInteractiveSession() error {
 	// Lookup table for OTP key display name to its OTPKey struct
 	otpKeyDisplayNameMap := make(map[string]*otp.OTPKey)
 
-	// All OTP keys for suggestions
+	// OTP keys available in this session, restricted by --tag
+	otpKeys := lookup.FilterByTags(i.andOTPBackup.OTPKeys, i.config.Tags)
+
+	// All OTP keys for suggestions, with their tags as description
 	suggestions := []prompt.Suggest{}
 
-	for idx, otpKey := range i.andOTPBackup.OTPKeys {
-		displayName := fmt.Sprintf("[%d] %s | %s", idx+1, otpKey.Issuer, otpKey.Label)
+	// Most used OTP keys are suggested first
+	indexes := make([]int, 0, len(i.andOTPBackup.OTPKeys))
+	for idx := range i.andOTPBackup.OTPKeys {
+		indexes = append(indexes, idx)
+	}
+
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return i.usage.Frequency(i.andOTPBackup.OTPKeys[indexes[a]]) >
+			i.usage.Frequency(i.andOTPBackup.OTPKeys[indexes[b]])
+	})
+
+	for _, idx := range indexes {
+		otpKey := i.andOTPBackup.OTPKeys[idx]
+		if !lookup.HasAnyTag(otpKey, i.config.Tags) {
+			continue
+		}
+
+		displayName := lookup.DisplayName(idx, otpKey)
 
 		otpKeyDisplayNameMap[displayName] = otpKey
 
 		suggestions = append(
 			suggestions,
-			prompt.Suggest{Text: displayName},
+			prompt.Suggest{Text: displayName, Description: strings.Join(otpKey.Tags, ", ")},
 		)
 	}
 
+	// Tag and command suggestions
+	tagSuggestions := []prompt.Suggest{}
+	for _, tagCount := range lookup.CountTags(otpKeys) {
+		tagSuggestions = append(tagSuggestions, prompt.Suggest{
+			Text:        lookup.TagPrefix + tagCount.Tag,
+			Description: fmt.Sprintf("%d OTP keys", tagCount.Count),
+		})
+	}
+
+	commandSuggestions := []prompt.Suggest{
+		{Text: commandTags, Description: "List all tags"},
+		{Text: commandGroup, Description: "List OTP keys grouped by tag"},
+	}
+
 	// Executor for the shell
 	executor := func(in string) {
 		in = strings.TrimSpace(in)
@@ -111,21 +133,80 @@ func (i *Interactive) startInteractiveSession() error {
 			return
 		}
 
-		otpKey, otpKeyExists := otpKeyDisplayNameMap[in]
-		if !otpKeyExists {
-			fmt.Printf("OTP key with name '%s' does not exist\n\n", in)
+		switch in {
+		case commandTags:
+			i.printTags(otpKeys)
+			return
+		case commandGroup:
+			i.printGroups(otpKeys)
 			return
 		}
 
+		tags, name := lookup.SplitTagFilter(in)
+
+		// Only tags, list the OTP keys having all of them
+		if name == "" {
+			i.printOTPKeys(tags)
+			return
+		}
+
+		// Exact display name, otherwise the single best fuzzy match
+		otpKey, otpKeyExists := otpKeyDisplayNameMap[name]
+		if !otpKeyExists {
+			idx, err := lookup.BestOf(i.andOTPBackup.OTPKeys, name, func(otpKey *otp.OTPKey) bool {
+				return lookup.HasAnyTag(otpKey, i.config.Tags) && lookup.HasAllTags(otpKey, tags)
+			}, i.usage.Frequency)
+
+			var ambiguousErr *lookup.AmbiguousError
+
+			if errors.As(err, &ambiguousErr) {
+				fmt.Printf("'%s' matches several OTP keys, did you mean:\n", name)
+				for _, candidate := range ambiguousErr.Candidates {
+					fmt.Printf("  %s\n", candidate)
+				}
+
+				fmt.Println()
+
+				return
+			} else if err != nil {
+				fmt.Printf("No OTP key matches '%s'\n\n", name)
+				return
+			}
+
+			otpKey = i.andOTPBackup.OTPKeys[idx]
+			fmt.Printf("Using %s\n", lookup.DisplayName(idx, otpKey))
+		}
+
 		token, err := otpKey.GenerateCode()
 		if err != nil {
 			fmt.Printf("Error during token generation: %v\n\n", err)
 			return
 		}
 
-		if err := clipboard.WriteAll(token); err != nil {
+		// Persist the advanced HOTP counter so that the next token is different
+		if otpKey.OTPType == "HOTP" {
+			if err := i.loader.Store(i.andOTPBackup, ""); err != nil {
+				fmt.Printf("HOTP counter advanced to %d but cannot be stored: %v\n", otpKey.Counter, err)
+			} else {
+				fmt.Printf("HOTP counter advanced to %d and stored\n", otpKey.Counter)
+			}
+		}
+
+		if err := i.usage.Record(otpKey); err != nil {
+			fmt.Printf("Unable to record OTP key usage: %v\n", err)
+		}
+
+		clearDelay, err := clipboard.CopyToken(token, otpKey, i.config.ClipboardClearAfter)
+
+		var clearErr *clipboard.ClearError
+
+		if errors.As(err, &clearErr) {
+			fmt.Printf("Warning: %v\n\n", err)
+		} else if err != nil {
 			fmt.Printf("Token: '%s'\n", token)
 			fmt.Printf("Cannot copy token to clipboard, error: %v\n", err)
+		} else if clearDelay > 0 {
+			fmt.Printf("Token copied to clipboard, clearing it in %s\n\n", clearDelay)
 		} else {
 			fmt.Print("Token copied to clipboard\n\n")
 		}
@@ -133,12 +214,32 @@ func (i *Interactive) startInteractiveSession() error {
 
 	// Completer for the shell
 	completer := func(in prompt.Document) []prompt.Suggest {
+		tags, name := lookup.SplitTagFilter(in.TextBeforeCursor())
+
 		// Do not suggest anything if a valid argument is already typed in
-		if _, ok := otpKeyDisplayNameMap[strings.TrimSpace(in.Text)]; ok {
+		if _, ok := otpKeyDisplayNameMap[strings.TrimSpace(name)]; ok {
 			return nil
 		}
 
-		return prompt.FilterContains(suggestions, in.GetWordBeforeCursor(), true)
+		word := in.GetWordBeforeCursor()
+
+		if strings.HasPrefix(word, ":") {
+			return prompt.FilterHasPrefix(commandSuggestions, word, true)
+		}
+
+		if strings.HasPrefix(word, lookup.TagPrefix) {
+			return prompt.FilterContains(tagSuggestions, word, true)
+		}
+
+		// Only suggest OTP keys having all the tags typed in
+		filtered := []prompt.Suggest{}
+		for _, suggestion := range suggestions {
+			if lookup.HasAllTags(otpKeyDisplayNameMap[suggestion.Text], tags) {
+				filtered = append(filtered, suggestion)
+			}
+		}
+
+		return rankSuggestions(filtered, otpKeyDisplayNameMap, word)
 	}
 
 	// Run the interactive shell
@@ -151,3 +252,96 @@ func (i *Interactive) startInteractiveSession() error {
 
 	return nil
 }
+
+// printTags prints every tag with the number of OTP keys having it
+func (i *Interactive) printTags(otpKeys []*otp.OTPKey) {
+	tagCounts := lookup.CountTags(otpKeys)
+	if len(tagCounts) == 0 {
+		fmt.Print("No tags\n\n")
+		return
+	}
+
+	for _, tagCount := range tagCounts {
+		fmt.Printf("%s%s (%d)\n", lookup.TagPrefix, tagCount.Tag, tagCount.Count)
+	}
+
+	fmt.Println()
+}
+
+// printGroups prints the OTP keys grouped by tag, OTP keys with several tags
+// are printed in each group
+func (i *Interactive) printGroups(otpKeys []*otp.OTPKey) {
+	for _, tagCount := range lookup.CountTags(otpKeys) {
+		fmt.Printf("%s%s\n", lookup.TagPrefix, tagCount.Tag)
+		i.printOTPKeysWith(func(otpKey *otp.OTPKey) bool {
+			return lookup.HasTag(otpKey, tagCount.Tag)
+		})
+	}
+
+	fmt.Println("Untagged")
+	i.printOTPKeysWith(func(otpKey *otp.OTPKey) bool {
+		return len(otpKey.Tags) == 0
+	})
+}
+
+// printOTPKeys prints the OTP keys having all the tags
+func (i *Interactive) printOTPKeys(tags []string) {
+	i.printOTPKeysWith(func(otpKey *otp.OTPKey) bool {
+		return lookup.HasAllTags(otpKey, tags)
+	})
+}
+
+// printOTPKeysWith prints the display names of the session's OTP keys
+// matching the predicate
+func (i *Interactive) printOTPKeysWith(matches func(otpKey *otp.OTPKey) bool) {
+	found := 0
+
+	for idx, otpKey := range i.andOTPBackup.OTPKeys {
+		if lookup.HasAnyTag(otpKey, i.config.Tags) && matches(otpKey) {
+			fmt.Printf("  %s\n", lookup.DisplayName(idx, otpKey))
+			found++
+		}
+	}
+
+	if found == 0 {
+		fmt.Println("  No OTP keys")
+	}
+
+	fmt.Println()
+}
+
+// rankSuggestions returns the suggestions whose OTP key fuzzily matches the
+// word, best match first, followed by the ones only containing the word in
+// their display name (e.g. a typed in index)
+func rankSuggestions(suggestions []prompt.Suggest, otpKeys map[string]*otp.OTPKey, word string) []prompt.Suggest {
+	if word == "" {
+		return suggestions
+	}
+
+	type scoredSuggestion struct {
+		suggestion prompt.Suggest
+		score      int
+	}
+
+	scored := []scoredSuggestion{}
+	rest := []prompt.Suggest{}
+
+	for _, suggestion := range suggestions {
+		if score, ok := lookup.ScoreKey(otpKeys[suggestion.Text], word); ok {
+			scored = append(scored, scoredSuggestion{suggestion: suggestion, score: score})
+		} else {
+			rest = append(rest, suggestion)
+		}
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	ranked := make([]prompt.Suggest, 0, len(scored))
+	for _, s := range scored {
+		ranked = append(ranked, s.suggestion)
+	}
+
+	return append(ranked, prompt.FilterContains(rest, word, true)...)
+}
@@ -1,162 +1,272 @@
 package kdeconnect
 
 import (
-	"bytes"
+	"context"
+	"fmt"
 	"log"
-	"os/exec"
-	"regexp"
-	"strings"
+	"time"
 
+	"github.com/godbus/dbus/v5"
 	"github.com/pkg/errors"
 )
 
-var (
-	cliListAvailableRegex = regexp.MustCompile(`^- (.*): ([a-z0-9]+).*$`)
-	mountSFTPHostRegex    = regexp.MustCompile(`^kdeconnect@(.+):.*$`)
-	ssSFTPPortRegex       = regexp.MustCompile(`[^\s]+\s+[^\s]+\s+[^\s]+\s+[^\s]+\s+(?:[0-9.]+:([0-9]+))\s+[^\s]+`)
+// D-Bus names of kdeconnectd. The daemon registers itself as
+// org.kde.kdeconnect, newer versions as org.kde.kdeconnect.daemon.
+var daemonServices = []string{"org.kde.kdeconnect", "org.kde.kdeconnect.daemon"}
+
+const (
+	daemonPath      = "/modules/kdeconnect"
+	daemonInterface = "org.kde.kdeconnect.daemon"
+	deviceInterface = "org.kde.kdeconnect.device"
+	sftpInterface   = "org.kde.kdeconnect.device.sftp"
+
+	// Default of how long to wait for a device's filesystem to be mounted,
+	// and how often to check its mount info meanwhile
+	defaultMountTimeout      = 30 * time.Second
+	defaultMountPollInterval = 500 * time.Millisecond
 )
 
 type Device struct {
 	// Name and ID of the device
-	Name string
-	ID   string
+	Name string `json:"name"`
+	ID   string `json:"id"`
+
+	// Whether the device is connected to the host, and paired with it
+	Reachable bool `json:"reachable"`
+	Paired    bool `json:"paired"`
 
 	// Host & port of the device's SFTP server. Will be empty strings if the
-	// device's filesystem is not yet mounted by KDE Connect via the
-	// "Browse this device" button.
-	SFTPHost string
-	SFTPPort string
+	// device's filesystem is not yet mounted by KDE Connect, see Client.Mount.
+	SFTPHost string `json:"sftp_host"`
+	SFTPPort string `json:"sftp_port"`
+}
+
+// BusConn is the part of a D-Bus connection used to query kdeconnectd.
+// Implemented by *dbus.Conn.
+type BusConn interface {
+	Object(dest string, path dbus.ObjectPath) dbus.BusObject
+}
+
+// Client queries kdeconnectd over D-Bus
+type Client struct {
+	conn    BusConn
+	service string
+
+	// How long Mount waits for the device's filesystem to be mounted, and how
+	// often it checks its mount info meanwhile
+	mountTimeout      time.Duration
+	mountPollInterval time.Duration
+}
+
+// NewClient creates a new Client using the given D-Bus connection, e.g. the
+// session bus
+func NewClient(conn BusConn) (*Client, error) {
+	bus := conn.Object("org.freedesktop.DBus", "/org/freedesktop/DBus")
+
+	for _, service := range daemonServices {
+		var hasOwner bool
+
+		if err := bus.Call("org.freedesktop.DBus.NameHasOwner", 0, service).Store(&hasOwner); err != nil {
+			return nil, errors.Wrap(err, "error looking up the KDE Connect daemon on D-Bus")
+		}
+
+		if hasOwner {
+			return &Client{
+				conn:              conn,
+				service:           service,
+				mountTimeout:      defaultMountTimeout,
+				mountPollInterval: defaultMountPollInterval,
+			}, nil
+		}
+	}
+
+	return nil, errors.New("the KDE Connect daemon is not running on the session D-Bus")
+}
+
+// NewSessionClient creates a new Client on the session D-Bus
+func NewSessionClient() (*Client, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, errors.Wrap(err, "error connecting to the session D-Bus")
+	}
+
+	return NewClient(conn)
 }
 
 // ListAvailableDevices returns a list of KDE Connect devices connected to
-// the host
-func ListAvailableDevices() ([]*Device, error) {
-	availableDevices, err := listAvailableDevices()
+// the host, i.e. paired and reachable devices
+func (c *Client) ListAvailableDevices() ([]*Device, error) {
+	devices, err := c.ListDevices()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, d := range availableDevices {
-		if err := populateSFTPHostPort(d); err != nil {
-			log.Printf("error getting KDE connect device host/port for device '%s'", d.Name)
+	availableDevices := []*Device{}
+
+	for _, device := range devices {
+		if device.Reachable && device.Paired {
+			availableDevices = append(availableDevices, device)
 		}
 	}
 
 	return availableDevices, nil
 }
 
-// listAvailableDevices returns a list of KDE Connect devices connected to
-// the host with `kdeconnect-cli -a`
-func listAvailableDevices() ([]*Device, error) {
-	var out bytes.Buffer
+// ListDevices returns a list of all KDE Connect devices known to the host,
+// including unreachable and unpaired ones
+func (c *Client) ListDevices() ([]*Device, error) {
+	var deviceIDs []string
 
-	cmd := exec.Command("kdeconnect-cli", "-a")
-	cmd.Stdout = &out
+	daemon := c.conn.Object(c.service, daemonPath)
 
-	if err := cmd.Run(); err != nil {
-		return nil, errors.Wrap(err, "error listing available KDE Connect devices")
+	// devices(onlyReachable, onlyPaired)
+	if err := daemon.Call(daemonInterface+".devices", 0, false, false).Store(&deviceIDs); err != nil {
+		return nil, errors.Wrap(err, "error listing KDE Connect devices")
 	}
 
-	// Parse the output, example:
-	//
-	// - S6 Lite: 82c27bf0c8d7fbc5 (paired and reachable)
-	// - Asus Max Pro M1: cc06e6c222be2ff6 (paired and reachable)
-	// 2 devices found
 	devices := []*Device{}
 
-	for _, line := range strings.Split(out.String(), "\n") {
-		if !strings.HasPrefix(line, "- ") {
-			continue
-		}
-
-		// Parse format like this:
-		// ^- Asus Max Pro M1: cc06e6c222be2ff6 (paired and reachable)$
-		matches := cliListAvailableRegex.FindAllStringSubmatch(line, -1)
-
-		if len(matches) != 1 {
-			continue
+	for _, deviceID := range deviceIDs {
+		device, err := c.device(deviceID)
+		if err != nil {
+			return nil, err
 		}
 
-		if len(matches[0]) != 3 {
-			continue
+		// The sftp plugin is only loaded for reachable devices
+		if device.Reachable && device.Paired {
+			if err := c.populateSFTPHostPort(device); err != nil {
+				log.Printf("error getting KDE connect device host/port for device '%s': %v", device.Name, err)
+			}
 		}
 
-		devices = append(
-			devices,
-			&Device{Name: matches[0][1], ID: matches[0][2]},
-		)
+		devices = append(devices, device)
 	}
 
 	return devices, nil
 }
 
-// populateSFTPHostPort populates the device's SFTP host/port field accordingly.
-// The device's host is obtained from `mount | grep <device-id>`, while the port
-// is obtained from `ss -plnaut | grep <device-ip>`.
-func populateSFTPHostPort(device *Device) error {
-	// Get the device's host
-	var mountOut bytes.Buffer
+// device returns the device with its name, reachability and pairing state
+func (c *Client) device(deviceID string) (*Device, error) {
+	deviceObject := c.deviceObject(deviceID, "")
+	device := &Device{ID: deviceID}
+
+	name, err := deviceObject.GetProperty(deviceInterface + ".name")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting the name of KDE Connect device '%s'", deviceID)
+	}
 
-	mountCmd := exec.Command("mount")
-	mountCmd.Stdout = &mountOut
+	device.Name, _ = name.Value().(string)
 
-	if err := mountCmd.Run(); err != nil {
-		return errors.Wrap(err, "error listing KDE Connect mounted volumes")
+	reachable, err := deviceObject.GetProperty(deviceInterface + ".isReachable")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting the reachability of KDE Connect device '%s'", deviceID)
 	}
 
-	// Get device IP from expected output format:
-	// kdeconnect@192.168.0.188:/ on /run/user/1000/82c27bf0c8d7fbc5 type fuse.sshfs (rw,nosuid,nodev,relatime,user_id=1000,group_id=1000)
-	for _, line := range strings.Split(mountOut.String(), "\n") {
-		if !strings.Contains(line, device.ID) || !strings.Contains(line, "kdeconnect") {
-			continue
-		}
+	device.Reachable, _ = reachable.Value().(bool)
+
+	// Older versions call a paired device trusted
+	paired, err := deviceObject.GetProperty(deviceInterface + ".isPaired")
+	if err != nil {
+		paired, err = deviceObject.GetProperty(deviceInterface + ".isTrusted")
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting the pairing state of KDE Connect device '%s'", deviceID)
+	}
 
-		matches := mountSFTPHostRegex.FindAllStringSubmatch(line, -1)
+	device.Paired, _ = paired.Value().(bool)
 
-		if len(matches) != 1 {
-			continue
-		}
+	return device, nil
+}
 
-		if len(matches[0]) != 2 {
-			continue
-		}
+// deviceObject returns the D-Bus object of a device, or of one of its plugins
+func (c *Client) deviceObject(deviceID string, plugin string) dbus.BusObject {
+	path := daemonPath + "/devices/" + deviceID
+	if plugin != "" {
+		path += "/" + plugin
+	}
+
+	return c.conn.Object(c.service, dbus.ObjectPath(path))
+}
+
+// populateSFTPHostPort populates the device's SFTP host/port field from the
+// sftp plugin's mount info, if the device's filesystem is mounted
+func (c *Client) populateSFTPHostPort(device *Device) error {
+	sftpPlugin := c.deviceObject(device.ID, "sftp")
 
-		device.SFTPHost = matches[0][1]
+	var mounted bool
+
+	if err := sftpPlugin.Call(sftpInterface+".isMounted", 0).Store(&mounted); err != nil {
+		return errors.Wrap(err, "error checking whether the device's filesystem is mounted")
 	}
 
-	if device.SFTPHost == "" {
+	if !mounted {
 		return nil
 	}
 
-	// Get device SFTP port
-	var ssOut bytes.Buffer
+	var mountInfo map[string]dbus.Variant
+
+	if err := sftpPlugin.Call(sftpInterface+".getMountInfo", 0).Store(&mountInfo); err != nil {
+		return errors.Wrap(err, "error getting the device's mount info")
+	}
 
-	ssCmd := exec.Command("ss", "-plnta")
-	ssCmd.Stdout = &ssOut
+	host, hasHost := mountInfo["ip"]
+	port, hasPort := mountInfo["port"]
 
-	if err := ssCmd.Run(); err != nil {
-		return errors.Wrap(err, "error listing KDE Connect established connections")
+	if !hasHost || !hasPort {
+		return fmt.Errorf("the device's mount info has no ip/port: %v", mountInfo)
 	}
 
-	// Get device port from expected output format:
-	// ESTAB     0      0                 192.168.0.138:41718            192.168.0.188:1739                    users:(("ssh",pid=361762,fd=3))
-	for _, line := range strings.Split(ssOut.String(), "\n") {
-		if !strings.Contains(line, device.SFTPHost) || !strings.Contains(line, "ssh") {
-			continue
-		}
+	// The port is an integer on most versions, but may be a string
+	device.SFTPHost = fmt.Sprint(host.Value())
+	device.SFTPPort = fmt.Sprint(port.Value())
 
-		matches := ssSFTPPortRegex.FindAllStringSubmatch(line, -1)
+	return nil
+}
 
-		if len(matches) != 1 {
-			continue
-		}
+// Mount mounts the device's filesystem like the "Browse this device" button,
+// then waits for the device's SFTP host & port to be known
+func (c *Client) Mount(device *Device) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.mountTimeout)
+	defer cancel()
+
+	sftpPlugin := c.deviceObject(device.ID, "sftp")
+
+	var mounted bool
+
+	if err := sftpPlugin.CallWithContext(ctx, sftpInterface+".mountAndWait", 0).Store(&mounted); err != nil {
+		return errors.Wrap(err, "error mounting the device's filesystem")
+	}
+
+	if !mounted {
+		var mountError string
 
-		if len(matches[0]) != 2 {
-			continue
+		if err := sftpPlugin.Call(sftpInterface+".getMountError", 0).Store(&mountError); err != nil || mountError == "" {
+			mountError = "unknown error"
 		}
 
-		device.SFTPPort = matches[0][1]
+		return fmt.Errorf("error mounting the device's filesystem: %s", mountError)
 	}
 
-	return nil
+	// The mount info may lag behind the mount
+	ticker := time.NewTicker(c.mountPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := c.populateSFTPHostPort(device)
+		if err == nil && device.SFTPHost != "" && device.SFTPPort != "" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return errors.Wrap(err, "timed out waiting for the device's mount info")
+			}
+
+			return errors.New("timed out waiting for the device's mount info")
+
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,516 @@
+package kdeconnect
+
+import (
+	"bufio"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// startBus starts a private session bus for the test and returns its address.
+// Skips the test if dbus-daemon isn't available.
+func startBus(t *testing.T) string {
+	t.Helper()
+
+	daemonPath, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not available")
+	}
+
+	cmd := exec.Command(daemonPath, "--session", "--nofork", "--print-address")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("error creating dbus-daemon stdout pipe: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start dbus-daemon: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Skipf("unable to read the dbus-daemon address: %v", err)
+	}
+
+	return strings.TrimSpace(address)
+}
+
+// connectBus opens a connection to the bus, closed at the end of the test
+func connectBus(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("dbus.Connect() error = %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// fakeDevice describes a device known to a fakeDaemon
+type fakeDevice struct {
+	id        string
+	name      string
+	reachable bool
+	paired    bool
+
+	// Use the isTrusted property of older versions instead of isPaired
+	trusted bool
+
+	// Mount info of the sftp plugin, nil if not mounted
+	mountInfo map[string]dbus.Variant
+
+	// Properties the device doesn't have
+	missing []string
+}
+
+// fakeDaemon is kdeconnectd's daemon object
+type fakeDaemon struct {
+	mu sync.Mutex
+
+	deviceIDs []string
+
+	// Arguments of the last devices() call
+	devicesArgs []bool
+}
+
+// Devices implements devices(onlyReachable, onlyPaired), returning every
+// known device regardless of the filters
+func (d *fakeDaemon) Devices(onlyReachable bool, onlyPaired bool) ([]string, *dbus.Error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.devicesArgs = []bool{onlyReachable, onlyPaired}
+
+	return d.deviceIDs, nil
+}
+
+// fakeSFTPPlugin is the sftp plugin object of a device
+type fakeSFTPPlugin struct {
+	mu        sync.Mutex
+	mountInfo map[string]dbus.Variant
+
+	// Result of mountAndWait(), and the error of a failed mount
+	mountOK    bool
+	mountError string
+
+	// Mount info after mountAndWait(), and how many isMounted() calls still
+	// report the filesystem as not mounted after it
+	mountedInfo map[string]dbus.Variant
+	mountLag    int
+	mounting    bool
+}
+
+func (p *fakeSFTPPlugin) IsMounted() (bool, *dbus.Error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.mounting && p.mountInfo == nil {
+		if p.mountLag > 0 {
+			p.mountLag--
+		} else {
+			p.mountInfo = p.mountedInfo
+		}
+	}
+
+	return p.mountInfo != nil, nil
+}
+
+func (p *fakeSFTPPlugin) MountAndWait() (bool, *dbus.Error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.mounting = p.mountOK
+
+	return p.mountOK, nil
+}
+
+func (p *fakeSFTPPlugin) GetMountError() (string, *dbus.Error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.mountError, nil
+}
+
+func (p *fakeSFTPPlugin) GetMountInfo() (map[string]dbus.Variant, *dbus.Error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.mountInfo == nil {
+		return map[string]dbus.Variant{}, nil
+	}
+
+	return p.mountInfo, nil
+}
+
+// D-Bus names of the fake plugin's methods
+var fakeSFTPPluginMethods = map[string]string{
+	"IsMounted":     "isMounted",
+	"GetMountInfo":  "getMountInfo",
+	"MountAndWait":  "mountAndWait",
+	"GetMountError": "getMountError",
+}
+
+// exportFakeDaemon exports a fake kdeconnectd knowing the given devices on the
+// connection, then takes the given service name
+func exportFakeDaemon(t *testing.T, conn *dbus.Conn, service string, devices ...fakeDevice) (
+	*fakeDaemon, map[string]*fakeSFTPPlugin,
+) {
+	t.Helper()
+
+	daemon := &fakeDaemon{deviceIDs: []string{}}
+	plugins := make(map[string]*fakeSFTPPlugin)
+
+	for _, device := range devices {
+		daemon.deviceIDs = append(daemon.deviceIDs, device.id)
+
+		pairedProperty := "isPaired"
+		if device.trusted {
+			pairedProperty = "isTrusted"
+		}
+
+		deviceProps := map[string]*prop.Prop{
+			"name":         {Value: device.name, Emit: prop.EmitFalse},
+			"isReachable":  {Value: device.reachable, Emit: prop.EmitFalse},
+			pairedProperty: {Value: device.paired, Emit: prop.EmitFalse},
+		}
+
+		for _, name := range device.missing {
+			delete(deviceProps, name)
+		}
+
+		devicePath := dbus.ObjectPath(daemonPath + "/devices/" + device.id)
+
+		_, err := prop.Export(conn, devicePath, map[string]map[string]*prop.Prop{deviceInterface: deviceProps})
+		if err != nil {
+			t.Fatalf("prop.Export() error = %v", err)
+		}
+
+		plugins[device.id] = &fakeSFTPPlugin{mountInfo: device.mountInfo}
+
+		err = conn.ExportWithMap(plugins[device.id], fakeSFTPPluginMethods, devicePath+"/sftp", sftpInterface)
+		if err != nil {
+			t.Fatalf("ExportWithMap() error = %v", err)
+		}
+	}
+
+	err := conn.ExportWithMap(daemon, map[string]string{"Devices": "devices"}, daemonPath, daemonInterface)
+	if err != nil {
+		t.Fatalf("ExportWithMap() error = %v", err)
+	}
+
+	reply, err := conn.RequestName(service, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("RequestName(%s) = %v, %v", service, reply, err)
+	}
+
+	return daemon, plugins
+}
+
+// newFakeClient starts a private bus with a fake kdeconnectd registered under
+// the given service name, knowing the given devices, and returns a Client of
+// it
+func newFakeClient(t *testing.T, service string, devices ...fakeDevice) (*Client, *fakeDaemon, map[string]*fakeSFTPPlugin) {
+	t.Helper()
+
+	address := startBus(t)
+	daemon, plugins := exportFakeDaemon(t, connectBus(t, address), service, devices...)
+
+	client, err := NewClient(connectBus(t, address))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	return client, daemon, plugins
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		owned   string
+		wantErr bool
+	}{
+		{name: "older daemon name", owned: "org.kde.kdeconnect"},
+		{name: "newer daemon name", owned: "org.kde.kdeconnect.daemon"},
+		{name: "daemon not running", owned: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := startBus(t)
+
+			if tt.owned != "" {
+				exportFakeDaemon(t, connectBus(t, address), tt.owned)
+			}
+
+			client, err := NewClient(connectBus(t, address))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NewClient() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			if client.service != tt.owned {
+				t.Errorf("service = %s, want %s", client.service, tt.owned)
+			}
+		})
+	}
+}
+
+func TestListDevices(t *testing.T) {
+	devices := []fakeDevice{
+		{
+			id: "abc123", name: "Phone", reachable: true, paired: true,
+			mountInfo: map[string]dbus.Variant{
+				"ip":   dbus.MakeVariant("192.168.1.10"),
+				"port": dbus.MakeVariant(int32(1739)),
+			},
+		},
+		{
+			id: "def456", name: "Tablet", reachable: true, paired: true,
+			mountInfo: map[string]dbus.Variant{
+				"ip":   dbus.MakeVariant("192.168.1.11"),
+				"port": dbus.MakeVariant("1740"),
+			},
+		},
+		{id: "ghi789", name: "Laptop", reachable: true, paired: true},
+		{id: "jkl012", name: "Old", reachable: true, paired: true, trusted: true},
+		{id: "mno345", name: "Away", reachable: false, paired: true},
+		{id: "pqr678", name: "Stranger", reachable: true, paired: false},
+	}
+
+	want := []*Device{
+		{Name: "Phone", ID: "abc123", Reachable: true, Paired: true, SFTPHost: "192.168.1.10", SFTPPort: "1739"},
+		{Name: "Tablet", ID: "def456", Reachable: true, Paired: true, SFTPHost: "192.168.1.11", SFTPPort: "1740"},
+		{Name: "Laptop", ID: "ghi789", Reachable: true, Paired: true},
+		{Name: "Old", ID: "jkl012", Reachable: true, Paired: true},
+		{Name: "Away", ID: "mno345", Reachable: false, Paired: true},
+		{Name: "Stranger", ID: "pqr678", Reachable: true, Paired: false},
+	}
+
+	for _, service := range daemonServices {
+		t.Run(service, func(t *testing.T) {
+			client, daemon, _ := newFakeClient(t, service, devices...)
+
+			got, err := client.ListDevices()
+			if err != nil {
+				t.Fatalf("ListDevices() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ListDevices() = %s, want %s", formatDevices(got), formatDevices(want))
+			}
+
+			// devices(onlyReachable, onlyPaired) lists every known device
+			daemon.mu.Lock()
+			args := daemon.devicesArgs
+			daemon.mu.Unlock()
+
+			if !reflect.DeepEqual(args, []bool{false, false}) {
+				t.Errorf("devices() called with %v, want [false false]", args)
+			}
+
+			available, err := client.ListAvailableDevices()
+			if err != nil {
+				t.Fatalf("ListAvailableDevices() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(available, want[:4]) {
+				t.Errorf("ListAvailableDevices() = %s, want %s", formatDevices(available), formatDevices(want[:4]))
+			}
+		})
+	}
+}
+
+func TestListDevicesMissingProperty(t *testing.T) {
+	client, _, _ := newFakeClient(t, "org.kde.kdeconnect.daemon", fakeDevice{
+		id: "abc123", name: "Phone", missing: []string{"isReachable"},
+	})
+
+	if _, err := client.ListDevices(); err == nil {
+		t.Errorf("ListDevices() succeeded without the isReachable property, want an error")
+	}
+}
+
+func TestPopulateSFTPHostPort(t *testing.T) {
+	tests := []struct {
+		name      string
+		mountInfo map[string]dbus.Variant
+		wantHost  string
+		wantPort  string
+		wantErr   bool
+	}{
+		{
+			name: "int port",
+			mountInfo: map[string]dbus.Variant{
+				"ip":   dbus.MakeVariant("10.0.0.2"),
+				"port": dbus.MakeVariant(int32(1739)),
+			},
+			wantHost: "10.0.0.2",
+			wantPort: "1739",
+		},
+		{
+			name: "string port",
+			mountInfo: map[string]dbus.Variant{
+				"ip":   dbus.MakeVariant("10.0.0.2"),
+				"port": dbus.MakeVariant("1739"),
+			},
+			wantHost: "10.0.0.2",
+			wantPort: "1739",
+		},
+		{
+			name: "not mounted",
+		},
+		{
+			name:      "missing ip",
+			mountInfo: map[string]dbus.Variant{"port": dbus.MakeVariant(int32(1739))},
+			wantErr:   true,
+		},
+		{
+			name:      "missing port",
+			mountInfo: map[string]dbus.Variant{"ip": dbus.MakeVariant("10.0.0.2")},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _, _ := newFakeClient(t, "org.kde.kdeconnect", fakeDevice{
+				id: "abc123", name: "Phone", reachable: true, paired: true, mountInfo: tt.mountInfo,
+			})
+
+			device := &Device{ID: "abc123"}
+
+			err := client.populateSFTPHostPort(device)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("populateSFTPHostPort() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("populateSFTPHostPort() error = %v", err)
+			}
+
+			if device.SFTPHost != tt.wantHost || device.SFTPPort != tt.wantPort {
+				t.Errorf(
+					"SFTP host/port = %q/%q, want %q/%q",
+					device.SFTPHost, device.SFTPPort, tt.wantHost, tt.wantPort,
+				)
+			}
+		})
+	}
+}
+
+func TestMount(t *testing.T) {
+	mountInfo := map[string]dbus.Variant{
+		"ip":   dbus.MakeVariant("10.0.0.2"),
+		"port": dbus.MakeVariant(int32(1739)),
+	}
+
+	tests := []struct {
+		name       string
+		mountOK    bool
+		mountError string
+		mountLag   int
+		wantErr    string
+	}{
+		{name: "mounted", mountOK: true},
+		{name: "mount info lags", mountOK: true, mountLag: 3},
+		{
+			name:       "mount fails",
+			mountOK:    false,
+			mountError: "device refused the connection",
+			wantErr:    "error mounting the device's filesystem: device refused the connection",
+		},
+		{
+			name:     "mount info never arrives",
+			mountOK:  true,
+			mountLag: 1 << 20,
+			wantErr:  "timed out waiting for the device's mount info",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _, plugins := newFakeClient(t, "org.kde.kdeconnect", fakeDevice{
+				id: "abc123", name: "Phone", reachable: true, paired: true,
+			})
+
+			client.mountTimeout = 200 * time.Millisecond
+			client.mountPollInterval = 5 * time.Millisecond
+
+			plugin := plugins["abc123"]
+			plugin.mu.Lock()
+			plugin.mountOK = tt.mountOK
+			plugin.mountError = tt.mountError
+			plugin.mountedInfo = mountInfo
+			plugin.mountLag = tt.mountLag
+			plugin.mu.Unlock()
+
+			device := &Device{ID: "abc123"}
+
+			err := client.Mount(device)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Mount() error = %v, want %s", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Mount() error = %v", err)
+			}
+
+			if device.SFTPHost != "10.0.0.2" || device.SFTPPort != "1739" {
+				t.Errorf("SFTP host/port = %q/%q, want \"10.0.0.2\"/\"1739\"", device.SFTPHost, device.SFTPPort)
+			}
+		})
+	}
+}
+
+// formatDevices formats devices for test failure messages
+func formatDevices(devices []*Device) string {
+	formatted := "["
+
+	for idx, device := range devices {
+		if idx > 0 {
+			formatted += " "
+		}
+
+		formatted += device.ID + ":" + device.Name + ":" + device.SFTPHost + ":" + device.SFTPPort
+		if device.Reachable {
+			formatted += ":reachable"
+		}
+
+		if device.Paired {
+			formatted += ":paired"
+		}
+	}
+
+	return formatted + "]"
+}
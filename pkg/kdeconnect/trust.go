@@ -0,0 +1,124 @@
+package kdeconnect
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotPaired is returned when KDE Connect stored no certificate for a device
+var ErrNotPaired = errors.New("KDE Connect stored no certificate for the device")
+
+// ConfigDir returns KDE Connect's configuration directory,
+// $XDG_CONFIG_HOME/kdeconnect or ~/.config/kdeconnect
+func ConfigDir() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+
+	if configHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "unable to find home directory")
+		}
+
+		configHome = filepath.Join(homeDir, ".config")
+	}
+
+	return filepath.Join(configHome, "kdeconnect"), nil
+}
+
+// TrustedPublicKey returns the public key of a paired device, taken from the
+// certificate KDE Connect stored in its trusted_devices file when pairing.
+// The device's SFTP server uses the same key pair as its host key. Returns
+// ErrNotPaired if there's no certificate for the device.
+func TrustedPublicKey(deviceID string) (crypto.PublicKey, error) {
+	configDir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(configDir, "trusted_devices"))
+	if os.IsNotExist(err) {
+		return nil, ErrNotPaired
+	} else if err != nil {
+		return nil, errors.Wrap(err, "error reading KDE Connect trusted devices")
+	}
+
+	values := readINISection(content, deviceID)
+
+	// Current KDE Connect versions store the device's certificate, older ones
+	// only its public key
+	if certificate, ok := values["certificate"]; ok {
+		block, _ := pem.Decode([]byte(certificate))
+		if block == nil {
+			return nil, errors.Errorf("invalid KDE Connect certificate of device %s", deviceID)
+		}
+
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid KDE Connect certificate of device %s", deviceID)
+		}
+
+		return parsed.PublicKey, nil
+	}
+
+	if publicKey, ok := values["publicKey"]; ok {
+		block, _ := pem.Decode([]byte(publicKey))
+		if block == nil {
+			return nil, errors.Errorf("invalid KDE Connect public key of device %s", deviceID)
+		}
+
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid KDE Connect public key of device %s", deviceID)
+		}
+
+		return parsed, nil
+	}
+
+	return nil, ErrNotPaired
+}
+
+// readINISection returns the values of a section of a QSettings INI file.
+// Values are unquoted and their escaped newlines expanded.
+func readINISection(content []byte, section string) map[string]string {
+	values := make(map[string]string)
+	inSection := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = line[1:len(line)-1] == section
+			continue
+		}
+
+		if !inSection {
+			continue
+		}
+
+		keyValue := strings.SplitN(line, "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(keyValue[1])
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+
+		values[strings.TrimSpace(keyValue[0])] = strings.ReplaceAll(value, `\n`, "\n")
+	}
+
+	return values
+}
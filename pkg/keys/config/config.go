@@ -0,0 +1,121 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+	loaderconfig "github.com/putrasattvika/andotp-cli/pkg/loader/config"
+	"github.com/putrasattvika/andotp-cli/pkg/password"
+)
+
+// KeyFields holds the OTP key fields to set on "keys add" and "keys edit". A
+// nil field is left unchanged on "keys edit", or set to its default value on
+// "keys add".
+type KeyFields struct {
+	Issuer    *string
+	Label     *string
+	OTPType   *string
+	Algorithm *string
+	Digits    *int
+	Period    *int
+	Counter   *int64
+	Tags      []string
+
+	// Where to read the OTP secret from, nil if the secret is unchanged
+	SecretSource password.Source
+}
+
+// Configuration used to modify the OTP keys of a backup
+type Config struct {
+	// Configuration for loading the andOTP backup
+	Loader *loaderconfig.Config
+
+	// Path to write the modified backup to. The backup file is overwritten if
+	// empty.
+	Output string
+
+	// Index, issuer, label or tag of the OTP key to edit, remove or retag
+	Query string
+
+	// OTP key fields for "keys add" and "keys edit"
+	Fields *KeyFields
+
+	// Tags to add to / remove from the OTP key for "keys tag"
+	AddTags    []string
+	RemoveTags []string
+
+	// Do not ask for confirmation before removing an OTP key
+	Yes bool
+}
+
+func ParseCmdConfig(cmdConfig *cmdconfig.Config, cmdKeysConfig *cmdconfig.KeysConfig) (*Config, error) {
+	loaderConfig, err := loaderconfig.ParseCmdConfig(cmdConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := func(flag string) bool {
+		return cmdKeysConfig.ChangedFlags[flag]
+	}
+
+	fields := &KeyFields{}
+
+	if changed("issuer") {
+		fields.Issuer = &cmdKeysConfig.Issuer
+	}
+
+	if changed("label") {
+		fields.Label = &cmdKeysConfig.Label
+	}
+
+	if changed("type") {
+		otpType := strings.ToUpper(cmdKeysConfig.OTPType)
+		fields.OTPType = &otpType
+	}
+
+	if changed("algorithm") {
+		algorithm := strings.ToUpper(cmdKeysConfig.Algorithm)
+		fields.Algorithm = &algorithm
+	}
+
+	if changed("digits") {
+		fields.Digits = &cmdKeysConfig.Digits
+	}
+
+	if changed("period") {
+		fields.Period = &cmdKeysConfig.Period
+	}
+
+	if changed("counter") {
+		fields.Counter = &cmdKeysConfig.Counter
+	}
+
+	if changed("tag") {
+		fields.Tags = cmdKeysConfig.Tags
+	}
+
+	// --secret-source
+	if changed("secret-source") {
+		secretSource, err := password.ParseSource(cmdKeysConfig.SecretSource)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid --secret-source")
+		}
+
+		if prompt, ok := secretSource.(*password.Prompt); ok {
+			prompt.Message = "Enter OTP secret: "
+		}
+
+		fields.SecretSource = secretSource
+	}
+
+	return &Config{
+		Loader:     loaderConfig,
+		Output:     cmdKeysConfig.Output,
+		Query:      cmdKeysConfig.Query,
+		Fields:     fields,
+		AddTags:    cmdKeysConfig.AddTags,
+		RemoveTags: cmdKeysConfig.RemoveTags,
+		Yes:        cmdKeysConfig.Yes,
+	}, nil
+}
@@ -0,0 +1,279 @@
+package keys
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+
+	andotpbackup "github.com/putrasattvika/andotp-cli/pkg/andotp/backup"
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+	"github.com/putrasattvika/andotp-cli/pkg/keys/config"
+	"github.com/putrasattvika/andotp-cli/pkg/loader"
+	"github.com/putrasattvika/andotp-cli/pkg/lookup"
+	"github.com/putrasattvika/andotp-cli/pkg/password"
+)
+
+// Keys modifies the OTP keys of an andOTP backup, then stores the backup back
+type Keys struct {
+	config *config.Config
+}
+
+// Create a new Keys
+func NewKeys(config *config.Config) (*Keys, error) {
+	return &Keys{config: config}, nil
+}
+
+// Add adds a new OTP key to the backup
+func (k *Keys) Add() error {
+	fields := k.config.Fields
+
+	if fields.Issuer == nil && fields.Label == nil {
+		return errors.New("either the issuer or the label of the new OTP key must be set")
+	}
+
+	otpKey := &otp.OTPKey{
+		OTPType:      "TOTP",
+		AlgorithmStr: "SHA1",
+		DigitsInt:    6,
+		Period:       30,
+		Tags:         []string{},
+		Thumbnail:    "Default",
+	}
+
+	// Steam Guard keys have fixed parameters
+	if fields.OTPType != nil && *fields.OTPType == "STEAM" {
+		otpKey.DigitsInt = 5
+	}
+
+	if fields.SecretSource == nil {
+		fields.SecretSource = &password.Prompt{Message: "Enter OTP secret: "}
+	}
+
+	loader_, backup, err := k.load()
+	if err != nil {
+		return err
+	}
+
+	if err := applyFields(otpKey, fields); err != nil {
+		return err
+	}
+
+	backup.OTPKeys = append(backup.OTPKeys, otpKey)
+
+	if err := k.store(loader_, backup); err != nil {
+		return err
+	}
+
+	log.Printf("Added OTP key %s", lookup.DisplayName(len(backup.OTPKeys)-1, otpKey))
+
+	return nil
+}
+
+// Edit changes the fields of an existing OTP key
+func (k *Keys) Edit() error {
+	loader_, backup, err := k.load()
+	if err != nil {
+		return err
+	}
+
+	idx, otpKey, err := lookup.Resolve(backup.OTPKeys, k.config.Query, nil)
+	if err != nil {
+		return err
+	}
+
+	// Edit a copy so that the key is left untouched on validation errors
+	editedOTPKey := *otpKey
+
+	if err := applyFields(&editedOTPKey, k.config.Fields); err != nil {
+		return err
+	}
+
+	backup.OTPKeys[idx] = &editedOTPKey
+
+	if err := k.store(loader_, backup); err != nil {
+		return err
+	}
+
+	log.Printf("Edited OTP key %s", lookup.DisplayName(idx, &editedOTPKey))
+
+	return nil
+}
+
+// Remove removes an OTP key from the backup
+func (k *Keys) Remove() error {
+	loader_, backup, err := k.load()
+	if err != nil {
+		return err
+	}
+
+	idx, otpKey, err := lookup.Resolve(backup.OTPKeys, k.config.Query, nil)
+	if err != nil {
+		return err
+	}
+
+	displayName := lookup.DisplayName(idx, otpKey)
+
+	if !k.config.Yes {
+		confirmed, err := confirm(fmt.Sprintf("Remove OTP key %s?", displayName))
+		if err != nil {
+			return err
+		}
+
+		if !confirmed {
+			return errors.New("aborted")
+		}
+	}
+
+	backup.OTPKeys = append(backup.OTPKeys[:idx], backup.OTPKeys[idx+1:]...)
+
+	if err := k.store(loader_, backup); err != nil {
+		return err
+	}
+
+	log.Printf("Removed OTP key %s", displayName)
+
+	return nil
+}
+
+// Tag adds and removes tags of an existing OTP key
+func (k *Keys) Tag() error {
+	loader_, backup, err := k.load()
+	if err != nil {
+		return err
+	}
+
+	idx, otpKey, err := lookup.Resolve(backup.OTPKeys, k.config.Query, nil)
+	if err != nil {
+		return err
+	}
+
+	removeTags := make(map[string]bool)
+	for _, tag := range k.config.RemoveTags {
+		removeTags[tag] = true
+	}
+
+	tags := []string{}
+	seenTags := make(map[string]bool)
+
+	for _, tag := range append(otpKey.Tags, k.config.AddTags...) {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "" || removeTags[tag] || seenTags[tag] {
+			continue
+		}
+
+		tags = append(tags, tag)
+		seenTags[tag] = true
+	}
+
+	otpKey.Tags = tags
+
+	if err := k.store(loader_, backup); err != nil {
+		return err
+	}
+
+	log.Printf("OTP key %s is now tagged [%s]", lookup.DisplayName(idx, otpKey), strings.Join(tags, ", "))
+
+	return nil
+}
+
+func (k *Keys) load() (*loader.Loader, *andotpbackup.Backup, error) {
+	loader_, err := loader.NewLoader(k.config.Loader)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create backup loader")
+	}
+
+	backup, err := loader_.Load()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to load OTP keys from andOTP backup file")
+	}
+
+	return loader_, backup, nil
+}
+
+func (k *Keys) store(loader_ *loader.Loader, backup *andotpbackup.Backup) error {
+	if err := loader_.Store(backup, k.config.Output); err != nil {
+		return errors.Wrap(err, "unable to store the modified backup")
+	}
+
+	return nil
+}
+
+// applyFields sets the given fields on the OTP key, then validates it
+func applyFields(otpKey *otp.OTPKey, fields *config.KeyFields) error {
+	if fields.Issuer != nil {
+		otpKey.Issuer = *fields.Issuer
+	}
+
+	if fields.Label != nil {
+		otpKey.Label = *fields.Label
+	}
+
+	if fields.OTPType != nil {
+		otpKey.OTPType = *fields.OTPType
+	}
+
+	if fields.Algorithm != nil {
+		otpKey.AlgorithmStr = *fields.Algorithm
+	}
+
+	if fields.Digits != nil {
+		otpKey.DigitsInt = *fields.Digits
+	}
+
+	if fields.Period != nil {
+		otpKey.Period = *fields.Period
+	}
+
+	if fields.Counter != nil {
+		otpKey.Counter = *fields.Counter
+	}
+
+	if fields.Tags != nil {
+		otpKey.Tags = fields.Tags
+	}
+
+	if fields.SecretSource != nil {
+		secretBuf, err := fields.SecretSource.Read()
+		if err != nil {
+			return errors.Wrap(err, "unable to read OTP secret")
+		}
+
+		secret := make([]byte, secretBuf.Size())
+		copy(secret, secretBuf.Bytes())
+		secretBuf.Destroy()
+
+		// Wipes secret
+		otpKey.SetSecret(secret)
+	}
+
+	if err := otpKey.Validate(); err != nil {
+		return errors.Wrap(err, "invalid OTP key")
+	}
+
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal
+func confirm(question string) (bool, error) {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		return false, errors.New("stdin is not a terminal, pass --yes to confirm")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, errors.Wrap(err, "error reading confirmation from stdin")
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+	andotpbackup "github.com/putrasattvika/andotp-cli/pkg/andotp/backup"
+	"github.com/putrasattvika/andotp-cli/pkg/password"
+)
+
+// Configuration used to load an andOTP backup
+type Config struct {
+	// URI to an andOTP encrypted backup file
+	//
+	// Supports three sources:
+	//   - local file (e.g. file:///home/myuser/otp_accounts.json.aes)
+	//   - KDE connect exposed device filesystem (e.g. kdeconnect://_/device-name-or-id/path/to/otp_accounts.json.aes)
+	//   - SFTP server (e.g. sftp://myuser@nas:22/~/backups/otp_accounts.json.aes)
+	BackupFileURI *url.URL
+
+	// Where to read the backup password from
+	PasswordSource password.Source
+
+	// Format of the backup file, nil to detect it from its contents
+	BackupFormat andotpbackup.Format
+
+	// Formats the backup format is detected from, including the ones
+	// configured by flags such as --pgp-private-key
+	Formats andotpbackup.Formats
+}
+
+func ParseCmdConfig(cmdConfig *cmdconfig.Config) (*Config, error) {
+	// --backup-file-uri
+	if cmdConfig.BackupFileURI == "" {
+		return nil, errors.New("--backup-file-uri cannot be empty")
+	}
+
+	backupFileURI, err := url.Parse(cmdConfig.BackupFileURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid --backup-file-uri")
+	}
+
+	// --password-source
+	passwordSource, err := password.ParseSource(cmdConfig.PasswordSource)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid --password-source")
+	}
+
+	// --pgp-private-key, added before resolving --backup-format so both
+	// format detection and --backup-format use it
+	formats := andotpbackup.DefaultFormats()
+
+	if cmdConfig.PGPPrivateKey != "" {
+		pgpFormat, err := andotpbackup.NewPGPFormat(cmdConfig.PGPPrivateKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid --pgp-private-key")
+		}
+
+		formats = formats.With(pgpFormat)
+	}
+
+	// --backup-format
+	var backupFormat andotpbackup.Format
+
+	if cmdConfig.BackupFormat != "" {
+		backupFormat, err = formats.ByName(cmdConfig.BackupFormat)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid --backup-format")
+		}
+	}
+
+	return &Config{
+		BackupFileURI:  backupFileURI,
+		PasswordSource: passwordSource,
+		BackupFormat:   backupFormat,
+		Formats:        formats,
+	}, nil
+}
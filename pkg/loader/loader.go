@@ -0,0 +1,148 @@
+package loader
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+
+	andotpbackup "github.com/putrasattvika/andotp-cli/pkg/andotp/backup"
+	andotpbackupprovider "github.com/putrasattvika/andotp-cli/pkg/andotp/backupprovider"
+	"github.com/putrasattvika/andotp-cli/pkg/loader/config"
+)
+
+// Loader fetches an andOTP backup from its provider and decrypts it
+type Loader struct {
+	config   *config.Config
+	provider andotpbackupprovider.BackupProvider
+
+	// Whether the loaded backup was encrypted, and the password used to
+	// decrypt it. Used to store the backup back in the same format.
+	encrypted bool
+	password  *memguard.Enclave
+}
+
+// Create a new Loader
+func NewLoader(config *config.Config) (*Loader, error) {
+	return &Loader{config: config}, nil
+}
+
+// Load fetches, parses and decrypts the andOTP backup
+func (l *Loader) Load() (*andotpbackup.Backup, error) {
+	// Get backup file provider
+	backupProvider, err := andotpbackupprovider.ConstructBackupProvider(l.config.BackupFileURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to construct backup file provider")
+	}
+
+	l.provider = backupProvider
+
+	// Fetch & parse backup contents
+	backupContents, err := backupProvider.FetchBackup()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch backup file")
+	}
+
+	var backup *andotpbackup.Backup
+
+	if l.config.BackupFormat != nil {
+		backup, err = andotpbackup.NewBackupWithFormat(backupContents, l.config.BackupFormat)
+	} else {
+		backup, err = andotpbackup.NewBackup(backupContents, l.config.Formats)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse backup file")
+	}
+
+	// Decrypt the backup
+	l.encrypted = backup.IsEncrypted()
+
+	if backup.IsEncrypted() {
+		passwordBuf, err := l.config.PasswordSource.Read()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read backup password")
+		}
+
+		if err := backup.Decrypt(passwordBuf); err != nil {
+			passwordBuf.Destroy()
+			return nil, errors.Wrap(err, "unable to decrypt backup")
+		}
+
+		// Keep the password sealed for re-encrypting the backup on Store()
+		l.password = passwordBuf.Seal()
+	}
+
+	// Run GC to remove decryption password and decrypted backup from memory
+	runtime.GC()
+
+	return backup, nil
+}
+
+// Writable returns true if the backup can be stored back to the backup file
+// URI with Store(), without an output path. Must be called after Load().
+func (l *Loader) Writable() bool {
+	return l.provider != nil && andotpbackupprovider.SupportsWrite(l.provider)
+}
+
+// Store serializes the backup in the same format it was loaded with, i.e.
+// encrypted with the same password if it was encrypted, then writes it to the
+// local file at outputPath. If outputPath is empty, the backup is written back
+// to the backup file URI, if its provider supports it. Must be called after
+// Load().
+func (l *Loader) Store(backup *andotpbackup.Backup, outputPath string) error {
+	var writer andotpbackupprovider.BackupWriter
+
+	if outputPath != "" {
+		localFile, err := andotpbackupprovider.NewLocalFile(outputPath)
+		if err != nil {
+			return errors.Wrap(err, "unable to construct output file provider")
+		}
+
+		writer = localFile
+	} else {
+		if !l.Writable() {
+			return fmt.Errorf(
+				"backup file URI scheme '%s' does not support writing back, write to a local file instead",
+				l.config.BackupFileURI.Scheme,
+			)
+		}
+
+		writer = l.provider.(andotpbackupprovider.BackupWriter)
+	}
+
+	contents, err := l.serialize(backup)
+	if err != nil {
+		return err
+	}
+
+	defer memguardcore.Wipe(contents)
+
+	if err := writer.StoreBackup(contents); err != nil {
+		return errors.Wrap(err, "unable to write backup file")
+	}
+
+	return nil
+}
+
+func (l *Loader) serialize(backup *andotpbackup.Backup) ([]byte, error) {
+	if !l.encrypted {
+		return backup.Serialize(nil)
+	}
+
+	// Empty passwords can't be sealed into an enclave
+	if l.password == nil {
+		return backup.Serialize(memguard.NewBuffer(0))
+	}
+
+	passwordBuf, err := l.password.Open()
+	if err != nil {
+		memguard.SafePanic(err)
+	}
+
+	defer passwordBuf.Destroy()
+
+	return backup.Serialize(passwordBuf)
+}
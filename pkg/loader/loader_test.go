@@ -0,0 +1,91 @@
+package loader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/awnumar/memguard"
+
+	andotpbackup "github.com/putrasattvika/andotp-cli/pkg/andotp/backup"
+	"github.com/putrasattvika/andotp-cli/pkg/loader/config"
+)
+
+const loaderTestBackup = `[{"secret": "JBSWY3DPEHPK3PXP", "issuer": "GitHub", "label": "me", "digits": 6,
+"type": "TOTP", "algorithm": "SHA1", "last_used": 0, "used_frequency": 0, "period": 30, "tags": []}]`
+
+// failingSource is a password source failing the test if it's read
+type failingSource struct {
+	t *testing.T
+}
+
+func (s *failingSource) Read() (*memguard.LockedBuffer, error) {
+	s.t.Errorf("password source read, want no password read")
+	return memguard.NewBufferFromBytes([]byte("unused")), nil
+}
+
+func TestLoadPGPWithUnencryptedKey(t *testing.T) {
+	dir := t.TempDir()
+
+	entity, err := openpgp.NewEntity("andotp-cli test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("openpgp.NewEntity() error = %v", err)
+	}
+
+	var privateKey bytes.Buffer
+	if err := entity.SerializePrivate(&privateKey, nil); err != nil {
+		t.Fatalf("SerializePrivate() error = %v", err)
+	}
+
+	privateKeyPath := filepath.Join(dir, "key.gpg")
+	if err := ioutil.WriteFile(privateKeyPath, privateKey.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Binary OpenPGP message, which legacy andOTP AES also recognizes
+	var backup bytes.Buffer
+
+	w, err := openpgp.Encrypt(&backup, openpgp.EntityList{entity}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("openpgp.Encrypt() error = %v", err)
+	}
+
+	w.Write([]byte(loaderTestBackup))
+	w.Close()
+
+	backupPath := filepath.Join(dir, "otp_accounts.json.gpg")
+	if err := ioutil.WriteFile(backupPath, backup.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pgpFormat, err := andotpbackup.NewPGPFormat(privateKeyPath)
+	if err != nil {
+		t.Fatalf("NewPGPFormat() error = %v", err)
+	}
+
+	loader, err := NewLoader(&config.Config{
+		BackupFileURI:  &url.URL{Scheme: "file", Path: backupPath},
+		PasswordSource: &failingSource{t: t},
+		Formats:        andotpbackup.DefaultFormats().With(pgpFormat),
+	})
+	if err != nil {
+		t.Fatalf("NewLoader() error = %v", err)
+	}
+
+	loaded, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if loaded.IsEncrypted() || loaded.Format() != andotpbackup.FormatAndOTPPGP {
+		t.Errorf("Load() = %s backup, encrypted %v, want a decrypted %s backup",
+			loaded.Format(), loaded.IsEncrypted(), andotpbackup.FormatAndOTPPGP)
+	}
+
+	if len(loaded.OTPKeys) != 1 || loaded.OTPKeys[0].Issuer != "GitHub" {
+		t.Errorf("OTPKeys = %+v, want the GitHub key", loaded.OTPKeys)
+	}
+}
@@ -0,0 +1,142 @@
+package lookup
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// NotFoundError is returned when no OTP key matches a query
+type NotFoundError struct {
+	Query string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("no OTP key matches '%s'", e.Query)
+}
+
+// AmbiguousError is returned when more than one OTP key matches a query
+type AmbiguousError struct {
+	Query      string
+	Candidates []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf(
+		"'%s' matches %d OTP keys: %s",
+		e.Query, len(e.Candidates), strings.Join(e.Candidates, ", "),
+	)
+}
+
+// Frequency returns how often an OTP key was used, to order matches by, most
+// used first. A nil Frequency keeps matches in backup order.
+type Frequency func(otpKey *otp.OTPKey) int
+
+// sortByFrequency stably sorts the OTP key indexes, most used first
+func sortByFrequency(otpKeys []*otp.OTPKey, indexes []int, frequency Frequency) {
+	if frequency == nil {
+		return
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return frequency(otpKeys[indexes[i]]) > frequency(otpKeys[indexes[j]])
+	})
+}
+
+// DisplayName returns the display name of the OTP key at the given index
+func DisplayName(idx int, otpKey *otp.OTPKey) string {
+	return fmt.Sprintf("[%d] %s | %s", idx+1, otpKey.Issuer, otpKey.Label)
+}
+
+// Find returns the indexes of OTP keys matching the query, which can be:
+//   - the 1-based index of the key, with or without brackets (e.g. 3 or [3])
+//   - the display name of the key (e.g. "[3] GitHub | myuser")
+//   - the issuer and label of the key separated by a colon (e.g. GitHub:myuser)
+//   - the issuer, label or one of the tags of the key
+//
+// Text comparisons are case-insensitive. The most specific kind of match wins,
+// e.g. a key matching by "issuer:label" hides keys only matching by tag.
+// Matches are ordered by the usage frequency.
+func Find(otpKeys []*otp.OTPKey, query string, frequency Frequency) []int {
+	query = strings.TrimSpace(query)
+
+	// Index
+	if idx, err := strconv.Atoi(strings.Trim(query, "[]")); err == nil {
+		if idx < 1 || idx > len(otpKeys) {
+			return nil
+		}
+
+		return []int{idx - 1}
+	}
+
+	// Display name, then issuer:label
+	for _, matches := range []func(idx int, otpKey *otp.OTPKey) bool{
+		func(idx int, otpKey *otp.OTPKey) bool {
+			return strings.EqualFold(DisplayName(idx, otpKey), query)
+		},
+		func(idx int, otpKey *otp.OTPKey) bool {
+			return strings.EqualFold(otpKey.Issuer+":"+otpKey.Label, query)
+		},
+		func(idx int, otpKey *otp.OTPKey) bool {
+			return strings.EqualFold(otpKey.Issuer, query) ||
+				strings.EqualFold(otpKey.Label, query) ||
+				HasTag(otpKey, query)
+		},
+	} {
+		found := []int{}
+
+		for idx, otpKey := range otpKeys {
+			if matches(idx, otpKey) {
+				found = append(found, idx)
+			}
+		}
+
+		if len(found) > 0 {
+			sortByFrequency(otpKeys, found, frequency)
+			return found
+		}
+	}
+
+	return nil
+}
+
+// Resolve returns the single OTP key matching the query. Returns a
+// *NotFoundError or *AmbiguousError if there isn't exactly one match, listing
+// the candidates by usage frequency.
+func Resolve(otpKeys []*otp.OTPKey, query string, frequency Frequency) (int, *otp.OTPKey, error) {
+	found := Find(otpKeys, query, frequency)
+
+	switch len(found) {
+	case 0:
+		return -1, nil, &NotFoundError{Query: query}
+
+	case 1:
+		return found[0], otpKeys[found[0]], nil
+	}
+
+	candidates := make([]string, 0, len(found))
+	for _, idx := range found {
+		candidates = append(candidates, DisplayName(idx, otpKeys[idx]))
+	}
+
+	return -1, nil, &AmbiguousError{Query: query, Candidates: candidates}
+}
+
+// ResolveFuzzy is like Resolve, but falls back to the single best fuzzy match
+// if nothing matches exactly. Only meant for read-only lookups, since a typo
+// may resolve to another OTP key.
+func ResolveFuzzy(otpKeys []*otp.OTPKey, query string, frequency Frequency) (int, *otp.OTPKey, error) {
+	if len(Find(otpKeys, query, frequency)) > 0 {
+		return Resolve(otpKeys, query, frequency)
+	}
+
+	idx, err := Best(otpKeys, query, frequency)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	return idx, otpKeys[idx], nil
+}
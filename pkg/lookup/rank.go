@@ -0,0 +1,121 @@
+package lookup
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+	"github.com/putrasattvika/andotp-cli/pkg/fuzzy"
+)
+
+// Maximum number of candidates listed when a fuzzy query is ambiguous
+const maxCandidates = 10
+
+// Match is an OTP key matching a fuzzy query, with its score
+type Match struct {
+	Index int
+	Score int
+}
+
+// ScoreKey fuzzily matches the query against the OTP key's issuer, label and
+// tags. Each whitespace-separated word of the query must match one of them,
+// e.g. "gh work" matches a GitHub key tagged "work". Returns false if the OTP
+// key doesn't match.
+func ScoreKey(otpKey *otp.OTPKey, query string) (int, bool) {
+	fields := append([]string{otpKey.Issuer, otpKey.Label}, otpKey.Tags...)
+	total := 0
+
+	for _, word := range strings.Fields(query) {
+		// Skip separators, e.g. the '|' of a display name
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+
+		best, matched := 0, false
+
+		for _, field := range fields {
+			if score, ok := fuzzy.Score(word, field); ok && (!matched || score > best) {
+				best, matched = score, true
+			}
+		}
+
+		if !matched {
+			return 0, false
+		}
+
+		total += best
+	}
+
+	return total, true
+}
+
+// Rank returns the OTP keys fuzzily matching the query, best match first.
+// OTP keys with the same score are ordered by usage frequency, then keep their
+// order.
+func Rank(otpKeys []*otp.OTPKey, query string, frequency Frequency) []Match {
+	return RankOf(otpKeys, query, nil, frequency)
+}
+
+// RankOf is like Rank, but only considers the OTP keys for which include
+// returns true. Indexes still refer to otpKeys. A nil include considers every
+// OTP key.
+func RankOf(
+	otpKeys []*otp.OTPKey, query string, include func(otpKey *otp.OTPKey) bool, frequency Frequency,
+) []Match {
+	matches := []Match{}
+
+	for idx, otpKey := range otpKeys {
+		if include != nil && !include(otpKey) {
+			continue
+		}
+
+		if score, ok := ScoreKey(otpKey, query); ok {
+			matches = append(matches, Match{Index: idx, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score || frequency == nil {
+			return matches[i].Score > matches[j].Score
+		}
+
+		return frequency(otpKeys[matches[i].Index]) > frequency(otpKeys[matches[j].Index])
+	})
+
+	return matches
+}
+
+// Best returns the index of the single best fuzzy match of the query. Returns
+// a *NotFoundError if nothing matches or an *AmbiguousError listing the top
+// candidates, by usage frequency, if several OTP keys share the best score.
+func Best(otpKeys []*otp.OTPKey, query string, frequency Frequency) (int, error) {
+	return BestOf(otpKeys, query, nil, frequency)
+}
+
+// BestOf is like Best, but only considers the OTP keys for which include
+// returns true, see RankOf
+func BestOf(
+	otpKeys []*otp.OTPKey, query string, include func(otpKey *otp.OTPKey) bool, frequency Frequency,
+) (int, error) {
+	matches := RankOf(otpKeys, query, include, frequency)
+
+	if len(matches) == 0 {
+		return -1, &NotFoundError{Query: query}
+	}
+
+	if len(matches) == 1 || matches[0].Score > matches[1].Score {
+		return matches[0].Index, nil
+	}
+
+	candidates := []string{}
+	for _, match := range matches {
+		if len(candidates) == maxCandidates {
+			break
+		}
+
+		candidates = append(candidates, DisplayName(match.Index, otpKeys[match.Index]))
+	}
+
+	return -1, &AmbiguousError{Query: query, Candidates: candidates}
+}
@@ -0,0 +1,133 @@
+package lookup
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// TagPrefix marks a tag filter in queries, e.g. "#work github"
+const TagPrefix = "#"
+
+// TagCount is a tag and the number of OTP keys having it
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// HasTag returns true if the OTP key has the tag, compared case-insensitively
+func HasTag(otpKey *otp.OTPKey, tag string) bool {
+	for _, keyTag := range otpKey.Tags {
+		if strings.EqualFold(keyTag, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasAnyTag returns true if the OTP key has any of the tags, or if no tags are
+// given
+func HasAnyTag(otpKey *otp.OTPKey, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+
+	for _, tag := range tags {
+		if HasTag(otpKey, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasAllTags returns true if the OTP key has every one of the tags
+func HasAllTags(otpKey *otp.OTPKey, tags []string) bool {
+	for _, tag := range tags {
+		if !HasTag(otpKey, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterByTags returns the OTP keys having any of the tags, or all OTP keys if
+// no tags are given
+func FilterByTags(otpKeys []*otp.OTPKey, tags []string) []*otp.OTPKey {
+	if len(tags) == 0 {
+		return otpKeys
+	}
+
+	filtered := []*otp.OTPKey{}
+
+	for _, otpKey := range otpKeys {
+		if HasAnyTag(otpKey, tags) {
+			filtered = append(filtered, otpKey)
+		}
+	}
+
+	return filtered
+}
+
+// CountTags returns every tag of the OTP keys with the number of OTP keys
+// having it, sorted by tag. Tags are compared case-insensitively like HasTag,
+// and spelled the way they're first seen.
+func CountTags(otpKeys []*otp.OTPKey) []TagCount {
+	counts := make(map[string]int)
+	spellings := make(map[string]string)
+
+	for _, otpKey := range otpKeys {
+		counted := make(map[string]bool)
+
+		for _, tag := range otpKey.Tags {
+			folded := strings.ToLower(tag)
+			if counted[folded] {
+				continue
+			}
+
+			counted[folded] = true
+
+			if _, ok := spellings[folded]; !ok {
+				spellings[folded] = tag
+			}
+
+			counts[folded]++
+		}
+	}
+
+	tagCounts := make([]TagCount, 0, len(counts))
+	for folded, count := range counts {
+		tagCounts = append(tagCounts, TagCount{Tag: spellings[folded], Count: count})
+	}
+
+	sort.Slice(tagCounts, func(i, j int) bool {
+		return strings.ToLower(tagCounts[i].Tag) < strings.ToLower(tagCounts[j].Tag)
+	})
+
+	return tagCounts
+}
+
+// SplitTagFilter splits the leading "#tag" words off a query, e.g.
+// "#work #dev github" into ["work", "dev"] and "github"
+func SplitTagFilter(query string) ([]string, string) {
+	tags := []string{}
+	rest := strings.TrimLeft(query, " ")
+
+	for strings.HasPrefix(rest, TagPrefix) {
+		word := rest
+		if end := strings.IndexByte(rest, ' '); end >= 0 {
+			word = rest[:end]
+		}
+
+		if tag := strings.TrimPrefix(word, TagPrefix); tag != "" {
+			tags = append(tags, tag)
+		}
+
+		rest = strings.TrimLeft(rest[len(word):], " ")
+	}
+
+	return tags, rest
+}
@@ -0,0 +1,81 @@
+package lookup
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// keysWithTags returns OTP keys having the given tags
+func keysWithTags(tags ...[]string) []*otp.OTPKey {
+	otpKeys := []*otp.OTPKey{}
+
+	for _, keyTags := range tags {
+		otpKeys = append(otpKeys, &otp.OTPKey{Tags: keyTags})
+	}
+
+	return otpKeys
+}
+
+func TestCountTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		otpKeys []*otp.OTPKey
+		want    []TagCount
+	}{
+		{
+			name:    "no tags",
+			otpKeys: keysWithTags([]string{}, nil),
+			want:    []TagCount{},
+		},
+		{
+			name:    "sorted case-insensitively",
+			otpKeys: keysWithTags([]string{"work", "Dev"}, []string{"work"}, []string{"banking"}),
+			want:    []TagCount{{Tag: "banking", Count: 1}, {Tag: "Dev", Count: 1}, {Tag: "work", Count: 2}},
+		},
+		{
+			name:    "different case across keys",
+			otpKeys: keysWithTags([]string{"Work"}, []string{"work"}, []string{"WORK", "dev"}),
+			want:    []TagCount{{Tag: "dev", Count: 1}, {Tag: "Work", Count: 3}},
+		},
+		{
+			name:    "different case on the same key",
+			otpKeys: keysWithTags([]string{"work", "Work"}, []string{"Work"}),
+			want:    []TagCount{{Tag: "work", Count: 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountTags(tt.otpKeys); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CountTags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitTagFilter(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantTags  []string
+		wantQuery string
+	}{
+		{query: "github", wantTags: []string{}, wantQuery: "github"},
+		{query: "#work github", wantTags: []string{"work"}, wantQuery: "github"},
+		{query: "  #work  #dev  git hub", wantTags: []string{"work", "dev"}, wantQuery: "git hub"},
+		{query: "#work", wantTags: []string{"work"}, wantQuery: ""},
+		{query: "# github", wantTags: []string{}, wantQuery: "github"},
+		{query: "github #work", wantTags: []string{}, wantQuery: "github #work"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			tags, query := SplitTagFilter(tt.query)
+
+			if !reflect.DeepEqual(tags, tt.wantTags) || query != tt.wantQuery {
+				t.Errorf("SplitTagFilter() = %v, %q, want %v, %q", tags, query, tt.wantTags, tt.wantQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package password
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// Source provides the password of an encrypted backup. The password is
+// returned inside a memguard buffer which must be destroyed by the caller.
+type Source interface {
+	Read() (*memguard.LockedBuffer, error)
+}
+
+// SourceConstructor is the signature of Source constructor
+type SourceConstructor func(value string) (Source, error)
+
+var (
+	// Mapping between password source spec prefix and its constructor
+	AvailableSources = map[string]SourceConstructor{
+		"prompt": ConstructPromptSource,
+		"file":   ConstructFileSource,
+		"env":    ConstructEnvSource,
+		"fd":     ConstructFDSource,
+		"cmd":    ConstructCommandSource,
+	}
+)
+
+// ParseSource parses a password source spec, which is either "prompt" or one
+// of "file:<path>", "env:<variable>", "fd:<number>" and "cmd:<shell command>".
+// An empty spec defaults to "prompt".
+func ParseSource(spec string) (Source, error) {
+	if spec == "" {
+		spec = "prompt"
+	}
+
+	kind, value := spec, ""
+	if idx := strings.Index(spec, ":"); idx >= 0 {
+		kind, value = spec[:idx], spec[idx+1:]
+	}
+
+	constructor, ok := AvailableSources[kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported password source '%s'", kind)
+	}
+
+	return constructor(value)
+}
+
+// ConstructPromptSource constructs a Source that prompts for the password
+func ConstructPromptSource(value string) (Source, error) {
+	return &Prompt{Message: DefaultPromptMessage}, nil
+}
+
+// ConstructFileSource constructs a Source that reads the password from a file
+func ConstructFileSource(value string) (Source, error) {
+	if value == "" {
+		return nil, errors.New("password file path cannot be empty")
+	}
+
+	return &File{filepath: value}, nil
+}
+
+// ConstructEnvSource constructs a Source that reads the password from an
+// environment variable
+func ConstructEnvSource(value string) (Source, error) {
+	if value == "" {
+		return nil, errors.New("password environment variable name cannot be empty")
+	}
+
+	return &Env{name: value}, nil
+}
+
+// ConstructFDSource constructs a Source that reads the password from an open
+// file descriptor, e.g. fd:0 for a password piped into stdin
+func ConstructFDSource(value string) (Source, error) {
+	fd, err := strconv.Atoi(value)
+	if err != nil || fd < 0 {
+		return nil, fmt.Errorf("invalid password file descriptor '%s'", value)
+	}
+
+	return &FD{fd: uintptr(fd)}, nil
+}
+
+// ConstructCommandSource constructs a Source that reads the password from the
+// output of a shell command, e.g. cmd:pass show andotp
+func ConstructCommandSource(value string) (Source, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, errors.New("password command cannot be empty")
+	}
+
+	return &Command{command: value}, nil
+}
+
+// DefaultPromptMessage is the message shown by Prompt unless overridden
+const DefaultPromptMessage = "Enter backup password: "
+
+// Prompt reads the password from the terminal without echoing it
+type Prompt struct {
+	// Message shown before reading the password
+	Message string
+}
+
+func (s *Prompt) Read() (*memguard.LockedBuffer, error) {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		return nil, errors.New("stdin is not a terminal, use a non-interactive password source instead")
+	}
+
+	// The prompt goes to stderr so that stdout can be used for command output
+	fmt.Fprint(os.Stderr, s.Message)
+	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprint(os.Stderr, "\n")
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading password from stdin")
+	}
+
+	// Wipes passwordBytes
+	return memguard.NewBufferFromBytes(passwordBytes), nil
+}
+
+// File reads the password from the first line of a file
+type File struct {
+	filepath string
+}
+
+func (s *File) Read() (*memguard.LockedBuffer, error) {
+	f, err := os.Open(s.filepath)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening password file")
+	}
+	defer f.Close()
+
+	return readFirstLine(f)
+}
+
+// Env reads the password from an environment variable. The variable is
+// removed from the environment afterwards so it won't be inherited by child
+// processes.
+type Env struct {
+	name string
+}
+
+func (s *Env) Read() (*memguard.LockedBuffer, error) {
+	password, ok := os.LookupEnv(s.name)
+	if !ok {
+		return nil, fmt.Errorf("password environment variable '%s' is not set", s.name)
+	}
+
+	os.Unsetenv(s.name)
+
+	return memguard.NewBufferFromBytes([]byte(password)), nil
+}
+
+// FD reads the password from the first line of an open file descriptor
+type FD struct {
+	fd uintptr
+}
+
+func (s *FD) Read() (*memguard.LockedBuffer, error) {
+	f := os.NewFile(s.fd, fmt.Sprintf("fd:%d", s.fd))
+	if f == nil {
+		return nil, fmt.Errorf("invalid password file descriptor %d", s.fd)
+	}
+
+	return readFirstLine(f)
+}
+
+// Command reads the password from the first line of a shell command's stdout,
+// the same way `pass` prints the password on its first line. The command's
+// stdin and stderr are inherited, so it may prompt e.g. for a GPG passphrase.
+type Command struct {
+	command string
+}
+
+func (s *Command) Read() (*memguard.LockedBuffer, error) {
+	cmd := exec.Command("sh", "-c", s.command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating password command stdout pipe")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "error starting password command")
+	}
+
+	passwordBuf, readErr := readFirstLine(stdout)
+
+	// Discard the rest of the output, e.g. metadata lines from `pass show`
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil {
+		if passwordBuf != nil {
+			passwordBuf.Destroy()
+		}
+
+		return nil, errors.Wrap(err, "password command failed")
+	}
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	return passwordBuf, nil
+}
+
+// readFirstLine reads a reader up to the first newline into a memguard buffer
+func readFirstLine(r io.Reader) (*memguard.LockedBuffer, error) {
+	passwordBuf, err := memguard.NewBufferFromReaderUntil(r, '\n')
+	if err != nil && err != io.EOF {
+		passwordBuf.Destroy()
+		return nil, errors.Wrap(err, "error reading password")
+	}
+
+	// Strip the carriage return of CRLF line endings
+	if passwordBytes := passwordBuf.Bytes(); len(passwordBytes) > 0 && passwordBytes[len(passwordBytes)-1] == '\r' {
+		trimmedBuf := memguard.NewBuffer(len(passwordBytes) - 1)
+		trimmedBuf.Copy(passwordBytes)
+		passwordBuf.Destroy()
+
+		return trimmedBuf, nil
+	}
+
+	return passwordBuf, nil
+}
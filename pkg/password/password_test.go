@@ -0,0 +1,202 @@
+package password
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Source
+		wantErr bool
+	}{
+		{spec: "", want: &Prompt{Message: DefaultPromptMessage}},
+		{spec: "prompt", want: &Prompt{Message: DefaultPromptMessage}},
+		{spec: "file:/run/secrets/andotp", want: &File{filepath: "/run/secrets/andotp"}},
+		{spec: "file:", wantErr: true},
+		{spec: "env:ANDOTP_PASSWORD", want: &Env{name: "ANDOTP_PASSWORD"}},
+		{spec: "env:", wantErr: true},
+		{spec: "fd:3", want: &FD{fd: 3}},
+		{spec: "fd:-1", wantErr: true},
+		{spec: "fd:stdin", wantErr: true},
+		{spec: "cmd:pass show andotp", want: &Command{command: "pass show andotp"}},
+		{spec: "cmd:echo a:b", want: &Command{command: "echo a:b"}},
+		{spec: "cmd:  ", wantErr: true},
+		{spec: "keyring:andotp", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseSource(tt.spec)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSource() = %#v, want an error", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseSource() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSource() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// firstLineTests are the contents sources read the first line of, and the
+// password read from them
+var firstLineTests = []struct {
+	name    string
+	content string
+	want    string
+}{
+	{name: "trailing newline", content: "hunter2\n", want: "hunter2"},
+	{name: "CRLF", content: "hunter2\r\n", want: "hunter2"},
+	{name: "no trailing newline", content: "hunter2", want: "hunter2"},
+	{name: "more lines", content: "hunter2\nurl: example.com\n", want: "hunter2"},
+	{name: "surrounding spaces kept", content: " hunter2 \n", want: " hunter2 "},
+	{name: "empty", content: "", want: ""},
+	{name: "empty line", content: "\n", want: ""},
+}
+
+func TestFileRead(t *testing.T) {
+	for _, tt := range firstLineTests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "password")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			passwordBuf, err := (&File{filepath: path}).Read()
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			defer passwordBuf.Destroy()
+
+			if got := string(passwordBuf.Bytes()); got != tt.want {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&File{filepath: filepath.Join(t.TempDir(), "missing")}).Read(); err == nil {
+		t.Errorf("Read() of a missing file succeeded, want an error")
+	}
+}
+
+func TestFDRead(t *testing.T) {
+	for _, tt := range firstLineTests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer r.Close()
+
+			w.WriteString(tt.content)
+			w.Close()
+
+			passwordBuf, err := (&FD{fd: r.Fd()}).Read()
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			defer passwordBuf.Destroy()
+
+			if got := string(passwordBuf.Bytes()); got != tt.want {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvRead(t *testing.T) {
+	const name = "ANDOTP_CLI_TEST_PASSWORD"
+
+	os.Setenv(name, "hunter2\n")
+	defer os.Unsetenv(name)
+
+	passwordBuf, err := (&Env{name: name}).Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	defer passwordBuf.Destroy()
+
+	// The whole value is the password
+	if got := string(passwordBuf.Bytes()); got != "hunter2\n" {
+		t.Errorf("Read() = %q, want %q", got, "hunter2\n")
+	}
+
+	if _, ok := os.LookupEnv(name); ok {
+		t.Errorf("%s still set after Read()", name)
+	}
+
+	if _, err := (&Env{name: name}).Read(); err == nil {
+		t.Errorf("Read() of an unset variable succeeded, want an error")
+	}
+}
+
+func TestCommandRead(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		wantErr bool
+	}{
+		{name: "first line", command: `printf 'hunter2\nurl: example.com\n'`, want: "hunter2"},
+		{name: "CRLF", command: `printf 'hunter2\r\n'`, want: "hunter2"},
+		{name: "no trailing newline", command: `printf hunter2`, want: "hunter2"},
+		{name: "empty output", command: `true`, want: ""},
+		{name: "non-zero exit", command: `exit 3`, wantErr: true},
+		{name: "non-zero exit after output", command: `echo hunter2; exit 1`, wantErr: true},
+		{name: "unknown command", command: `andotp-cli-test-missing-command`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := ParseSource(fmt.Sprintf("cmd:%s", tt.command))
+			if err != nil {
+				t.Fatalf("ParseSource() error = %v", err)
+			}
+
+			passwordBuf, err := source.Read()
+
+			if tt.wantErr {
+				if err == nil {
+					passwordBuf.Destroy()
+					t.Errorf("Read() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			defer passwordBuf.Destroy()
+
+			if got := string(passwordBuf.Bytes()); got != tt.want {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
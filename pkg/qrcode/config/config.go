@@ -0,0 +1,59 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+	loaderconfig "github.com/putrasattvika/andotp-cli/pkg/loader/config"
+)
+
+// Configuration used to render the QR code of an OTP key
+type Config struct {
+	// Configuration for loading the andOTP backup
+	Loader *loaderconfig.Config
+
+	// Index, issuer, label or tag of the OTP key to render
+	Query string
+
+	// Path to write the QR code as a PNG image to, empty to render it on the
+	// terminal
+	PNGOutput string
+
+	// Width & height of the PNG image in pixels
+	PNGSize int
+
+	// Render dark modules as filled blocks, for terminals with a light
+	// background
+	Invert bool
+
+	// Fall back to the best fuzzy match if no OTP key matches the query
+	// exactly
+	Fuzzy bool
+}
+
+func ParseCmdConfig(cmdConfig *cmdconfig.Config, cmdQRConfig *cmdconfig.QRConfig) (*Config, error) {
+	loaderConfig, err := loaderconfig.ParseCmdConfig(cmdConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// <query>
+	if strings.TrimSpace(cmdQRConfig.Query) == "" {
+		return nil, errors.New("OTP key query cannot be empty")
+	}
+
+	// --png-size
+	if cmdQRConfig.PNGSize <= 0 {
+		return nil, errors.New("--png-size must be positive")
+	}
+
+	return &Config{
+		Loader:    loaderConfig,
+		Query:     cmdQRConfig.Query,
+		PNGOutput: cmdQRConfig.PNGOutput,
+		PNGSize:   cmdQRConfig.PNGSize,
+		Invert:    cmdQRConfig.Invert,
+		Fuzzy:     cmdQRConfig.Fuzzy,
+	}, nil
+}
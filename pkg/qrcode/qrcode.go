@@ -0,0 +1,78 @@
+package qrcode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/loader"
+	"github.com/putrasattvika/andotp-cli/pkg/lookup"
+	"github.com/putrasattvika/andotp-cli/pkg/qrcode/config"
+)
+
+// QRCode renders the otpauth:// URI of an OTP key as a QR code, to enroll the
+// key on another authenticator
+type QRCode struct {
+	config *config.Config
+}
+
+// Create a new QRCode
+func NewQRCode(config *config.Config) (*QRCode, error) {
+	return &QRCode{config: config}, nil
+}
+
+// Run renders the QR code of the OTP key matching the query on the terminal,
+// or writes it as a PNG image
+func (q *QRCode) Run() error {
+	loader_, err := loader.NewLoader(q.config.Loader)
+	if err != nil {
+		return errors.Wrap(err, "unable to create backup loader")
+	}
+
+	backup, err := loader_.Load()
+	if err != nil {
+		return errors.Wrap(err, "unable to load OTP keys from andOTP backup file")
+	}
+
+	resolve := lookup.Resolve
+	if q.config.Fuzzy {
+		resolve = lookup.ResolveFuzzy
+	}
+
+	idx, otpKey, err := resolve(backup.OTPKeys, q.config.Query, nil)
+	if err != nil {
+		return err
+	}
+
+	uri, err := otpKey.URI()
+	if err != nil {
+		return errors.Wrap(err, "unable to create otpauth URI")
+	}
+
+	if q.config.PNGOutput != "" {
+		pngBytes, err := RenderPNG(uri, q.config.PNGSize)
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(q.config.PNGOutput, pngBytes, 0600); err != nil {
+			return errors.Wrap(err, "unable to write QR code PNG image")
+		}
+
+		log.Printf("QR code of OTP key %s written to %s", lookup.DisplayName(idx, otpKey), q.config.PNGOutput)
+
+		return nil
+	}
+
+	rendered, err := RenderTerminal(uri, q.config.Invert)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(rendered)
+	fmt.Println(lookup.DisplayName(idx, otpKey))
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/pkg/errors"
+)
+
+const (
+	// pngQuietZone is the width of the blank border around PNG QR codes in
+	// modules, as the spec asks
+	pngQuietZone = 4
+
+	// terminalQuietZone is the width of the blank border around terminal QR
+	// codes in modules. The spec asks for 4 modules, but 2 is enough for phone
+	// cameras and keeps the QR code small enough for most terminals.
+	terminalQuietZone = 2
+)
+
+// RenderTerminal renders the content as a QR code using Unicode half-block
+// characters, each character representing two vertically-stacked modules.
+//
+// By default light modules are drawn as blocks, which shows up as a dark code
+// on a light background on terminals with a dark background. Set invert for
+// terminals with a light background.
+func RenderTerminal(content string, invert bool) (string, error) {
+	code, err := encode(content)
+	if err != nil {
+		return "", err
+	}
+
+	size := code.Bounds().Dx()
+
+	// Whether the module at (x, y) is drawn, including the quiet zone
+	filled := func(x, y int) bool {
+		x, y = x-terminalQuietZone, y-terminalQuietZone
+
+		dark := false
+		if x >= 0 && y >= 0 && x < size && y < size {
+			dark = isDark(code.At(x, y))
+		}
+
+		return dark == invert
+	}
+
+	var out strings.Builder
+
+	for y := 0; y < size+2*terminalQuietZone; y += 2 {
+		for x := 0; x < size+2*terminalQuietZone; x++ {
+			top := filled(x, y)
+			bottom := y+1 < size+2*terminalQuietZone && filled(x, y+1)
+
+			switch {
+			case top && bottom:
+				out.WriteString("█")
+			case top:
+				out.WriteString("▀")
+			case bottom:
+				out.WriteString("▄")
+			default:
+				out.WriteString(" ")
+			}
+		}
+
+		out.WriteString("\n")
+	}
+
+	return out.String(), nil
+}
+
+// RenderPNG renders the content as a QR code PNG image of roughly the given
+// width & height in pixels, rounded down to a multiple of the module count
+func RenderPNG(content string, size int) ([]byte, error) {
+	code, err := encode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := code.Bounds().Dx() + 2*pngQuietZone
+
+	scale := size / modules
+	if scale < 1 {
+		scale = 1
+	}
+
+	img := image.NewGray(image.Rect(0, 0, modules*scale, modules*scale))
+
+	for y := 0; y < modules*scale; y++ {
+		for x := 0; x < modules*scale; x++ {
+			moduleX, moduleY := x/scale-pngQuietZone, y/scale-pngQuietZone
+
+			pixel := color.Gray{Y: 0xff}
+			if moduleX >= 0 && moduleY >= 0 && moduleX < modules-2*pngQuietZone && moduleY < modules-2*pngQuietZone {
+				if isDark(code.At(moduleX, moduleY)) {
+					pixel = color.Gray{Y: 0}
+				}
+			}
+
+			img.SetGray(x, y, pixel)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, errors.Wrap(err, "error encoding QR code PNG image")
+	}
+
+	return out.Bytes(), nil
+}
+
+// encode encodes the content into a QR code with one pixel per module
+func encode(content string) (barcode.Barcode, error) {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding QR code")
+	}
+
+	return code, nil
+}
+
+func isDark(c color.Color) bool {
+	return color.GrayModel.Convert(c).(color.Gray).Y < 0x80
+}
@@ -0,0 +1,73 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const testURI = "otpauth://totp/GitHub:me?secret=JBSWY3DPEHPK3PXP&issuer=GitHub"
+
+func TestRenderPNGQuietZone(t *testing.T) {
+	code, err := encode(testURI)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+
+	modules := code.Bounds().Dx() + 2*pngQuietZone
+	scale := 4
+
+	content, err := RenderPNG(testURI, modules*scale)
+	if err != nil {
+		t.Fatalf("RenderPNG() error = %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+
+	if got := img.Bounds().Dx(); got != modules*scale {
+		t.Fatalf("RenderPNG() width = %d, want %d", got, modules*scale)
+	}
+
+	border := pngQuietZone * scale
+
+	// The border is blank, the finder pattern's corner starts right after it
+	for i := 0; i < border; i++ {
+		for _, p := range [][2]int{{i, border}, {border, i}, {i, i}} {
+			if isDark(img.At(p[0], p[1])) {
+				t.Fatalf("pixel %v is dark, want the %d modules quiet zone blank", p, pngQuietZone)
+			}
+		}
+	}
+
+	if !isDark(img.At(border, border)) {
+		t.Errorf("pixel (%d, %d) is light, want the finder pattern's dark corner", border, border)
+	}
+}
+
+func TestRenderTerminalQuietZone(t *testing.T) {
+	code, err := encode(testURI)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+
+	out, err := RenderTerminal(testURI, true)
+	if err != nil {
+		t.Fatalf("RenderTerminal() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+
+	if got, want := utf8.RuneCountInString(lines[0]), code.Bounds().Dx()+2*terminalQuietZone; got != want {
+		t.Errorf("RenderTerminal() width = %d, want %d", got, want)
+	}
+
+	// Two modules per line, the first line is all quiet zone
+	if strings.TrimSpace(lines[0]) != "" {
+		t.Errorf("RenderTerminal() first line = %q, want blank", lines[0])
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	cmdconfig "github.com/putrasattvika/andotp-cli/cmd/config"
+	"github.com/putrasattvika/andotp-cli/pkg/clipboard"
+	loaderconfig "github.com/putrasattvika/andotp-cli/pkg/loader/config"
+)
+
+// Configuration used to start the TUI dashboard
+type Config struct {
+	// Configuration for loading the andOTP backup
+	Loader *loaderconfig.Config
+
+	// When to clear tokens copied to the clipboard
+	ClipboardClearAfter clipboard.ClearAfter
+}
+
+func ParseCmdConfig(cmdConfig *cmdconfig.Config) (*Config, error) {
+	loaderConfig, err := loaderconfig.ParseCmdConfig(cmdConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// --clipboard-clear-after
+	clearAfter, err := clipboard.ParseClearAfter(cmdConfig.ClipboardClearAfter)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid --clipboard-clear-after")
+	}
+
+	return &Config{
+		Loader: loaderConfig,
+
+		ClipboardClearAfter: clearAfter,
+	}, nil
+}
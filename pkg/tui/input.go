@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+type keyKind int
+
+// Keys handled by the dashboard
+const (
+	keyRune keyKind = iota
+	keyEnter
+	keyBackspace
+	keyEscape
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyQuit
+)
+
+type keyEvent struct {
+	kind keyKind
+	r    rune
+}
+
+// Escape sequences sent by terminals for the special keys
+var escapeSequences = map[string]keyKind{
+	"\x1b[A":  keyUp,
+	"\x1bOA":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1bOB":  keyDown,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+}
+
+// readKeys reads key presses from the raw mode terminal into the channel until
+// the reader fails
+func readKeys(r io.Reader, keys chan<- keyEvent) {
+	buf := make([]byte, 64)
+
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// parseKeys parses the bytes of a single read into key presses. Unknown escape
+// sequences are ignored.
+func parseKeys(in []byte) []keyEvent {
+	keys := []keyEvent{}
+
+	for len(in) > 0 {
+		if in[0] == 0x1b {
+			if len(in) == 1 {
+				keys = append(keys, keyEvent{kind: keyEscape})
+				break
+			}
+
+			matched := false
+
+			for seq, kind := range escapeSequences {
+				if len(in) >= len(seq) && string(in[:len(seq)]) == seq {
+					keys = append(keys, keyEvent{kind: kind})
+					in = in[len(seq):]
+					matched = true
+
+					break
+				}
+			}
+
+			if !matched {
+				// Drop the rest of the unknown escape sequence
+				break
+			}
+
+			continue
+		}
+
+		switch in[0] {
+		case '\r', '\n':
+			keys = append(keys, keyEvent{kind: keyEnter})
+		case 0x7f, 0x08:
+			keys = append(keys, keyEvent{kind: keyBackspace})
+		case 0x03, 0x04:
+			// ctrl+c, ctrl+d
+			keys = append(keys, keyEvent{kind: keyQuit})
+		case 0x10:
+			// ctrl+p
+			keys = append(keys, keyEvent{kind: keyUp})
+		case 0x0e:
+			// ctrl+n
+			keys = append(keys, keyEvent{kind: keyDown})
+		default:
+			r, size := utf8.DecodeRune(in)
+			if r >= 0x20 && r != utf8.RuneError {
+				keys = append(keys, keyEvent{kind: keyRune, r: r})
+			}
+
+			in = in[size:]
+			continue
+		}
+
+		in = in[1:]
+	}
+
+	return keys
+}
@@ -0,0 +1,399 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+
+	andotpbackup "github.com/putrasattvika/andotp-cli/pkg/andotp/backup"
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+	"github.com/putrasattvika/andotp-cli/pkg/clipboard"
+	"github.com/putrasattvika/andotp-cli/pkg/loader"
+	"github.com/putrasattvika/andotp-cli/pkg/lookup"
+	"github.com/putrasattvika/andotp-cli/pkg/tui/config"
+	"github.com/putrasattvika/andotp-cli/pkg/usage"
+)
+
+// Width of the countdown bar, in cells
+const countdownBarWidth = 10
+
+// Default size when the terminal size can't be read
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// ANSI escape sequences used to draw the dashboard
+const (
+	ansiAltScreenOn  = "\x1b[?1049h"
+	ansiAltScreenOff = "\x1b[?1049l"
+	ansiCursorHide   = "\x1b[?25l"
+	ansiCursorShow   = "\x1b[?25h"
+	ansiHome         = "\x1b[H"
+	ansiClearLine    = "\x1b[K"
+	ansiClearBelow   = "\x1b[J"
+	ansiReverse      = "\x1b[7m"
+	ansiDim          = "\x1b[2m"
+	ansiReset        = "\x1b[0m"
+)
+
+// TUI is a full-screen dashboard showing the live tokens of every OTP key
+type TUI struct {
+	config *config.Config
+	loader *loader.Loader
+	backup *andotpbackup.Backup
+	usage  *usage.Store
+
+	// Current filter and the selected row among the filtered OTP keys
+	filter   string
+	selected int
+	offset   int
+
+	// Tokens of HOTP keys generated in this session, since HOTP tokens are
+	// only generated on request
+	hotpTokens map[*otp.OTPKey]string
+
+	// Message shown in the status line
+	status string
+}
+
+// Create a new TUI
+func NewTUI(config *config.Config) (*TUI, error) {
+	return &TUI{
+		config:     config,
+		hotpTokens: make(map[*otp.OTPKey]string),
+	}, nil
+}
+
+// Start loads the backup and runs the dashboard until the user quits
+func (t *TUI) Start() error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("the dashboard needs an interactive terminal")
+	}
+
+	loader_, err := loader.NewLoader(t.config.Loader)
+	if err != nil {
+		return errors.Wrap(err, "unable to create backup loader")
+	}
+
+	backup, err := loader_.Load()
+	if err != nil {
+		return errors.Wrap(err, "unable to load OTP keys from andOTP backup file")
+	}
+
+	t.loader = loader_
+	t.backup = backup
+
+	// Most used OTP keys are shown first
+	t.usage = usage.OpenOrEmpty()
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return errors.Wrap(err, "unable to switch the terminal to raw mode")
+	}
+
+	fmt.Print(ansiAltScreenOn + ansiCursorHide)
+
+	defer func() {
+		fmt.Print(ansiCursorShow + ansiAltScreenOff)
+		_ = term.Restore(int(os.Stdin.Fd()), oldState)
+	}()
+
+	return t.run()
+}
+
+func (t *TUI) run() error {
+	keys := make(chan keyEvent)
+	go readKeys(os.Stdin, keys)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	t.draw()
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || !t.handleKey(key) {
+				return nil
+			}
+		case <-ticker.C:
+		}
+
+		t.draw()
+	}
+}
+
+// handleKey updates the dashboard state, returns false if the user quits
+func (t *TUI) handleKey(key keyEvent) bool {
+	visible := t.visibleKeys()
+
+	switch key.kind {
+	case keyQuit:
+		return false
+	case keyEscape:
+		if t.filter == "" {
+			return false
+		}
+
+		t.filter = ""
+		t.selected = 0
+	case keyRune:
+		t.filter += string(key.r)
+		t.selected = 0
+	case keyBackspace:
+		if t.filter != "" {
+			_, size := utf8.DecodeLastRuneInString(t.filter)
+			t.filter = t.filter[:len(t.filter)-size]
+			t.selected = 0
+		}
+	case keyUp:
+		t.selected--
+	case keyDown:
+		t.selected++
+	case keyPageUp:
+		t.selected -= t.pageSize()
+	case keyPageDown:
+		t.selected += t.pageSize()
+	case keyEnter:
+		if t.selected < len(visible) {
+			otpKey := visible[t.selected]
+			t.copyToken(otpKey)
+
+			// Recording the usage may reorder the OTP keys, keep the copied one
+			// selected
+			visible = t.visibleKeys()
+			for idx, visibleKey := range visible {
+				if visibleKey == otpKey {
+					t.selected = idx
+				}
+			}
+		}
+	}
+
+	if t.selected >= len(visible) {
+		t.selected = len(visible) - 1
+	}
+
+	if t.selected < 0 {
+		t.selected = 0
+	}
+
+	return true
+}
+
+// copyToken copies the current token of the OTP key to the clipboard. HOTP
+// keys are advanced and the backup is stored to persist their counter. Failing
+// to store the counter or to record the usage doesn't prevent copying the
+// token, it's reported on the status line after it.
+func (t *TUI) copyToken(otpKey *otp.OTPKey) {
+	token, err := otpKey.GenerateCode()
+	if err != nil {
+		t.status = fmt.Sprintf("Error during token generation: %v", err)
+		return
+	}
+
+	warnings := []string{}
+
+	if otpKey.OTPType == "HOTP" {
+		t.hotpTokens[otpKey] = token
+
+		if err := t.loader.Store(t.backup, ""); err != nil {
+			warnings = append(warnings, fmt.Sprintf("HOTP counter advanced to %d but cannot be stored: %v", otpKey.Counter, err))
+		}
+	}
+
+	if err := t.usage.Record(otpKey); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Unable to record OTP key usage: %v", err))
+	}
+
+	clearDelay, err := clipboard.CopyToken(token, otpKey, t.config.ClipboardClearAfter)
+
+	var clearErr *clipboard.ClearError
+
+	if errors.As(err, &clearErr) {
+		warnings = append(warnings, clearErr.Error())
+	} else if err != nil {
+		t.status = strings.Join(append([]string{fmt.Sprintf("Cannot copy token to clipboard: %v", err)}, warnings...), "; ")
+		return
+	}
+
+	t.status = fmt.Sprintf("Token of %s | %s copied to clipboard", otpKey.Issuer, otpKey.Label)
+	if clearDelay > 0 {
+		t.status += fmt.Sprintf(", clearing it in %s", clearDelay)
+	}
+
+	if len(warnings) > 0 {
+		t.status += "; " + strings.Join(warnings, "; ")
+	}
+}
+
+// visibleKeys returns the OTP keys fuzzily matching the filter, best match
+// first, or every OTP key by usage frequency if there's no filter
+func (t *TUI) visibleKeys() []*otp.OTPKey {
+	visible := []*otp.OTPKey{}
+
+	for _, match := range lookup.Rank(t.backup.OTPKeys, t.filter, t.usage.Frequency) {
+		visible = append(visible, t.backup.OTPKeys[match.Index])
+	}
+
+	return visible
+}
+
+// terminalSize returns the width and height of the terminal
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return defaultWidth, defaultHeight
+	}
+
+	return width, height
+}
+
+// pageSize returns the number of OTP key rows that fit on the screen, leaving
+// space for the header, filter and status lines
+func (t *TUI) pageSize() int {
+	_, height := terminalSize()
+
+	if height-4 < 1 {
+		return 1
+	}
+
+	return height - 4
+}
+
+// draw redraws the whole screen
+func (t *TUI) draw() {
+	width, _ := terminalSize()
+	visible := t.visibleKeys()
+	pageSize := t.pageSize()
+
+	// Scroll so that the selected row is visible
+	if t.selected < t.offset {
+		t.offset = t.selected
+	}
+
+	if t.selected >= t.offset+pageSize {
+		t.offset = t.selected - pageSize + 1
+	}
+
+	var screen bytes.Buffer
+
+	screen.WriteString(ansiHome)
+
+	writeLine(&screen, width, fmt.Sprintf(
+		"andOTP-cli  %d/%d keys  [type] filter  [up/down] select  [enter] copy  [esc] quit",
+		len(visible), len(t.backup.OTPKeys),
+	))
+	writeLine(&screen, width, "> "+t.filter)
+
+	now := time.Now()
+
+	for row := t.offset; row < len(visible) && row < t.offset+pageSize; row++ {
+		otpKey := visible[row]
+		line := t.formatRow(otpKey, now, width)
+
+		if row == t.selected {
+			line = ansiReverse + line + ansiReset
+		}
+
+		screen.WriteString(line + ansiClearLine + "\r\n")
+	}
+
+	screen.WriteString(ansiClearBelow)
+
+	if t.status != "" {
+		screen.WriteString("\r\n" + ansiDim + truncate(t.status, width) + ansiReset)
+	}
+
+	_, _ = os.Stdout.Write(screen.Bytes())
+}
+
+// formatRow formats an OTP key's row: its name, token and countdown
+func (t *TUI) formatRow(otpKey *otp.OTPKey, now time.Time, width int) string {
+	var token, countdown string
+
+	if otpKey.OTPType == "HOTP" {
+		token = "------"
+		if hotpToken, ok := t.hotpTokens[otpKey]; ok {
+			token = hotpToken
+		}
+
+		countdown = fmt.Sprintf("counter %d", otpKey.Counter)
+	} else {
+		generated, err := otpKey.GenerateCode()
+		if err != nil {
+			token = "error"
+		} else {
+			token = groupDigits(generated)
+		}
+
+		countdown = countdownBar(otpKey.Period, now)
+	}
+
+	right := fmt.Sprintf("  %-9s  %s", token, countdown)
+
+	nameWidth := width - utf8.RuneCountInString(right)
+	if nameWidth < 10 {
+		nameWidth = 10
+	}
+
+	name := fmt.Sprintf("%s | %s", otpKey.Issuer, otpKey.Label)
+	if len(otpKey.Tags) > 0 {
+		name += " [" + strings.Join(otpKey.Tags, ", ") + "]"
+	}
+
+	return truncate(fmt.Sprintf("%-*s", nameWidth, truncate(name, nameWidth))+right, width)
+}
+
+// countdownBar renders the time left in the current period as a bar followed
+// by the remaining seconds
+func countdownBar(period int, now time.Time) string {
+	if period <= 0 {
+		period = 30
+	}
+
+	remaining := period - int(now.Unix()%int64(period))
+	filled := (remaining*countdownBarWidth + period - 1) / period
+
+	return strings.Repeat("█", filled) + strings.Repeat("░", countdownBarWidth-filled) +
+		fmt.Sprintf(" %2ds", remaining)
+}
+
+// groupDigits splits numeric tokens into two groups for readability, e.g.
+// "123 456"
+func groupDigits(token string) string {
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return token
+		}
+	}
+
+	if len(token) < 6 {
+		return token
+	}
+
+	half := len(token) / 2
+
+	return token[:half] + " " + token[half:]
+}
+
+// writeLine writes a full line of the screen, truncated to the terminal width
+func writeLine(screen *bytes.Buffer, width int, line string) {
+	screen.WriteString(truncate(line, width) + ansiClearLine + "\r\n")
+}
+
+// truncate cuts the string to at most width runes
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	return string([]rune(s)[:width])
+}
@@ -0,0 +1,214 @@
+package twofas
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	memguardcore "github.com/awnumar/memguard/core"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// Key derivation parameters of encrypted 2FAS backups
+const (
+	pbkdf2Iterations = 10000
+	keyLen           = 32
+)
+
+// backup is the structure of a 2FAS Authenticator backup (.2fas). Either
+// services or servicesEncrypted is set.
+type backup struct {
+	SchemaVersion     int       `json:"schemaVersion"`
+	Services          []service `json:"services"`
+	ServicesEncrypted string    `json:"servicesEncrypted"`
+	Groups            []group   `json:"groups"`
+}
+
+type group struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type service struct {
+	Name    string     `json:"name"`
+	Secret  string     `json:"secret"`
+	GroupID string     `json:"groupId"`
+	OTP     serviceOTP `json:"otp"`
+}
+
+type serviceOTP struct {
+	Label     string `json:"label"`
+	Account   string `json:"account"`
+	Issuer    string `json:"issuer"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"`
+	Algorithm string `json:"algorithm"`
+	Counter   int64  `json:"counter"`
+	TokenType string `json:"tokenType"`
+}
+
+// IsBackup returns true if the content looks like a 2FAS backup
+func IsBackup(content []byte) bool {
+	var b struct {
+		SchemaVersion *int             `json:"schemaVersion"`
+		Services      *json.RawMessage `json:"services"`
+	}
+
+	if err := json.Unmarshal(content, &b); err != nil {
+		return false
+	}
+
+	return b.SchemaVersion != nil && b.Services != nil
+}
+
+// IsEncrypted returns true if the 2FAS backup's services are encrypted
+func IsEncrypted(content []byte) (bool, error) {
+	b := &backup{}
+	if err := json.Unmarshal(content, b); err != nil {
+		return false, errors.Wrap(err, "invalid 2FAS backup")
+	}
+
+	return b.ServicesEncrypted != "", nil
+}
+
+// Decode parses the OTP keys of a 2FAS backup. The password is only used for
+// encrypted backups and may be nil for plaintext ones.
+func Decode(content []byte, password *memguard.LockedBuffer) ([]*otp.OTPKey, error) {
+	b := &backup{}
+	if err := json.Unmarshal(content, b); err != nil {
+		return nil, errors.Wrap(err, "invalid 2FAS backup")
+	}
+
+	services := b.Services
+
+	if b.ServicesEncrypted != "" {
+		if password == nil {
+			return nil, errors.New("2FAS backup is encrypted but no password was given")
+		}
+
+		decrypted, err := decryptServices(b.ServicesEncrypted, password)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(decrypted, &services)
+		memguardcore.Wipe(decrypted)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid 2FAS backup services")
+		}
+	}
+
+	groupNames := make(map[string]string)
+	for _, g := range b.Groups {
+		groupNames[g.ID] = g.Name
+	}
+
+	otpKeys := make([]*otp.OTPKey, 0, len(services))
+
+	for _, s := range services {
+		otpKey := &otp.OTPKey{
+			Issuer:       s.OTP.Issuer,
+			Label:        s.OTP.Account,
+			OTPType:      strings.ToUpper(s.OTP.TokenType),
+			AlgorithmStr: strings.ToUpper(s.OTP.Algorithm),
+			DigitsInt:    s.OTP.Digits,
+			Period:       s.OTP.Period,
+			Counter:      s.OTP.Counter,
+			Tags:         []string{},
+			Thumbnail:    "Default",
+		}
+
+		// Older backups don't have some of the fields
+		if otpKey.Issuer == "" {
+			otpKey.Issuer = s.Name
+		}
+
+		if otpKey.Label == "" {
+			otpKey.Label = s.OTP.Label
+		}
+
+		if otpKey.OTPType == "" {
+			otpKey.OTPType = "TOTP"
+		}
+
+		if otpKey.AlgorithmStr == "" {
+			otpKey.AlgorithmStr = "SHA1"
+		}
+
+		if otpKey.DigitsInt == 0 {
+			otpKey.DigitsInt = 6
+		}
+
+		if otpKey.Period == 0 {
+			otpKey.Period = 30
+		}
+
+		// 2FAS groups become andOTP tags
+		if name, ok := groupNames[s.GroupID]; ok {
+			otpKey.Tags = append(otpKey.Tags, name)
+		}
+
+		otpKey.SetSecret([]byte(s.Secret))
+
+		if err := otpKey.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid 2FAS service '%s:%s'", otpKey.Issuer, otpKey.Label)
+		}
+
+		otpKeys = append(otpKeys, otpKey)
+	}
+
+	// Run GC to remove the plaintext secrets from memory
+	b, services = nil, nil
+	runtime.GC()
+
+	return otpKeys, nil
+}
+
+// decryptServices decrypts the servicesEncrypted field, formatted as
+// "<base64 ciphertext+tag>:<base64 salt>:<base64 IV>"
+func decryptServices(servicesEncrypted string, password *memguard.LockedBuffer) ([]byte, error) {
+	parts := strings.Split(servicesEncrypted, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid 2FAS encrypted services, expected 3 parts but got %d", len(parts))
+	}
+
+	decoded := make([][]byte, len(parts))
+	for idx, part := range parts {
+		var err error
+		if decoded[idx], err = base64.StdEncoding.DecodeString(part); err != nil {
+			return nil, errors.Wrap(err, "invalid 2FAS encrypted services")
+		}
+	}
+
+	ciphertext, salt, iv := decoded[0], decoded[1], decoded[2]
+
+	key := pbkdf2.Key(password.Bytes(), salt, pbkdf2Iterations, keyLen, sha256.New)
+	defer memguardcore.Wipe(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AES cipher")
+	}
+
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AES-GCM cipher")
+	}
+
+	plaintext, err := aesgcm.Open(nil, iv, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt 2FAS backup, wrong password?")
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,231 @@
+package twofas
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awnumar/memguard"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// keyFields are the fields of an OTP key read from a 2FAS backup, with its
+// plaintext secret
+type keyFields struct {
+	Issuer    string
+	Label     string
+	OTPType   string
+	Algorithm string
+	Digits    int
+	Period    int
+	Counter   int64
+	Tags      []string
+	Secret    string
+}
+
+func fieldsOf(k *otp.OTPKey) keyFields {
+	secretBuf := k.SecretBuffer()
+	defer secretBuf.Destroy()
+
+	fields := keyFields{
+		Issuer:    k.Issuer,
+		Label:     k.Label,
+		OTPType:   k.OTPType,
+		Algorithm: k.AlgorithmStr,
+		Digits:    k.DigitsInt,
+		Tags:      k.Tags,
+		Secret:    string(secretBuf.Bytes()),
+	}
+
+	if k.OTPType == "HOTP" {
+		fields.Counter = k.Counter
+	} else {
+		fields.Period = k.Period
+	}
+
+	return fields
+}
+
+const plaintextTestBackup = `{
+    "schemaVersion": 4,
+    "services": [
+        {
+            "name": "GitHub",
+            "secret": "JBSWY3DPEHPK3PXP",
+            "groupId": "g1",
+            "otp": {"label": "GitHub:me", "account": "me", "issuer": "GitHub", "digits": 6, "period": 30,
+                "algorithm": "SHA1", "counter": 0, "tokenType": "TOTP"}
+        },
+        {
+            "name": "Bank",
+            "secret": "GEZDGNBVGY3TQOJQ",
+            "otp": {"account": "token", "issuer": "Bank", "digits": 8, "algorithm": "SHA256", "counter": 7,
+                "tokenType": "HOTP"}
+        },
+        {
+            "name": "Old Service",
+            "secret": "MFRGGZDFMZTWQ2LK",
+            "groupId": "unknown",
+            "otp": {"label": "old@example.com"}
+        }
+    ],
+    "groups": [{"id": "g1", "name": "Work"}]
+}`
+
+// Services of plaintextTestBackup without the last one, encrypted with the
+// password "hunter2", the salt 0xe0..0xff and the IV 0x00..0x0b, computed
+// independently with PBKDF2-SHA256 and AES-256-GCM
+const encryptedTestServices = "7OtDUzMaA/Qm8wKFppfdiCzJbPjqIH/VQ28ihSMZFCqXIpHXXhf4fQD/G+55NBS/R/FEkcoWfVZ/B5uemI9boIkcgk" +
+	"iQ/6rRb7jsB82VsDYTVxScDwurWtMccDxJFLQTgBc5ECuHXiddenshUdPtGubUVYf435h209LtDQxs4oYJE8FQteit7pFIW1fOr7Xb66yIgdsV" +
+	"XE6Jm0snVvINvYTp+CVuGbpaFHGkwKYc50W2HNovk7aotNTai0TMKt5ZL+IYOt+rnnBLTvGndYL+3+6h5097KCEfHHCp+KwLCDRVnhZGCG5+Gk5y" +
+	"fvm/p5fUiPXFcogQ4KS/qwxy6c7r5EGFLrggZdfMsXxEJ5kzLg531yJupa+KD6+b1sUU9T47BUaBCBCEegRC12LhGHtAIV2ECDyFTcgM8mBXPMPu" +
+	"E6cuK7NFNnCTZ5mQbwAUra++E/fCT/P9CWk2WqzCFaUaKXnPliC0vV174Q==" +
+	":4OHi4+Tl5ufo6err7O3u7/Dx8vP09fb3+Pn6+/z9/v8=:AAECAwQFBgcICQoL"
+
+var wantTestKeys = []keyFields{
+	{
+		Issuer: "GitHub", Label: "me", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Period: 30,
+		Tags: []string{"Work"}, Secret: "JBSWY3DPEHPK3PXP",
+	},
+	{
+		Issuer: "Bank", Label: "token", OTPType: "HOTP", Algorithm: "SHA256", Digits: 8, Counter: 7,
+		Tags: []string{}, Secret: "GEZDGNBVGY3TQOJQ",
+	},
+	{
+		Issuer: "Old Service", Label: "old@example.com", OTPType: "TOTP", Algorithm: "SHA1", Digits: 6, Period: 30,
+		Tags: []string{}, Secret: "MFRGGZDFMZTWQ2LK",
+	},
+}
+
+// encryptedBackup returns a 2FAS backup with the given encrypted services
+func encryptedBackup(servicesEncrypted string) []byte {
+	return []byte(`{"schemaVersion": 4, "services": [], "servicesEncrypted": "` + servicesEncrypted + `",
+		"groups": [{"id": "g1", "name": "Work"}]}`)
+}
+
+func TestDecode(t *testing.T) {
+	// Kept referenced until the end, memguard destroys unreachable buffers
+	password := memguard.NewBufferFromBytes([]byte("hunter2"))
+	defer password.Destroy()
+
+	wrongPassword := memguard.NewBufferFromBytes([]byte("wrong"))
+	defer wrongPassword.Destroy()
+
+	tests := []struct {
+		name          string
+		content       []byte
+		password      *memguard.LockedBuffer
+		wantEncrypted bool
+		want          []keyFields
+		wantErr       string
+	}{
+		{
+			name:    "plaintext",
+			content: []byte(plaintextTestBackup),
+			want:    wantTestKeys,
+		},
+		{
+			name:          "encrypted",
+			content:       encryptedBackup(encryptedTestServices),
+			password:      password,
+			wantEncrypted: true,
+			want:          wantTestKeys[:2],
+		},
+		{
+			name:          "wrong password",
+			content:       encryptedBackup(encryptedTestServices),
+			password:      wrongPassword,
+			wantEncrypted: true,
+			wantErr:       "wrong password",
+		},
+		{
+			name:          "no password",
+			content:       encryptedBackup(encryptedTestServices),
+			wantEncrypted: true,
+			wantErr:       "no password was given",
+		},
+		{
+			name:          "missing part",
+			content:       encryptedBackup(encryptedTestServices[:strings.LastIndex(encryptedTestServices, ":")]),
+			password:      password,
+			wantEncrypted: true,
+			wantErr:       "expected 3 parts but got 2",
+		},
+		{
+			name:          "invalid base64",
+			content:       encryptedBackup("not base64!" + encryptedTestServices[strings.Index(encryptedTestServices, ":"):]),
+			password:      password,
+			wantEncrypted: true,
+			wantErr:       "invalid 2FAS encrypted services",
+		},
+		{
+			name:          "truncated ciphertext",
+			content:       encryptedBackup("AAAA" + encryptedTestServices[strings.Index(encryptedTestServices, ":"):]),
+			password:      password,
+			wantEncrypted: true,
+			wantErr:       "wrong password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsBackup(tt.content) {
+				t.Errorf("IsBackup() = false, want true")
+			}
+
+			encrypted, err := IsEncrypted(tt.content)
+			if err != nil {
+				t.Fatalf("IsEncrypted() error = %v", err)
+			}
+
+			if encrypted != tt.wantEncrypted {
+				t.Errorf("IsEncrypted() = %v, want %v", encrypted, tt.wantEncrypted)
+			}
+
+			otpKeys, err := Decode(tt.content, tt.password)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Decode() error = %v, want an error containing %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			got := []keyFields{}
+			for _, otpKey := range otpKeys {
+				got = append(got, fieldsOf(otpKey))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "2FAS backup", content: plaintextTestBackup, want: true},
+		{name: "missing services", content: `{"schemaVersion": 4}`, want: false},
+		{name: "Aegis vault", content: `{"version": 1, "header": {}, "db": {}}`, want: false},
+		{name: "not JSON", content: "otpauth://totp/me?secret=JBSWY3DPEHPK3PXP", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsBackup([]byte(tt.content)); got != tt.want {
+				t.Errorf("IsBackup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
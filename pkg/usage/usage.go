@@ -0,0 +1,226 @@
+package usage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/awnumar/memguard"
+	"github.com/pkg/errors"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+// Entry is the local usage of an OTP key
+type Entry struct {
+	// Number of tokens generated
+	Count int `json:"count"`
+
+	// Time of the last generated token, in milliseconds since the epoch like
+	// andOTP's last_used
+	LastUsed int64 `json:"last_used"`
+}
+
+// Size of the key OTP key IDs are derived with
+const keySize = 32
+
+// Store is the local usage of the OTP keys, persisted in a JSON file. OTP keys
+// are identified by an HMAC of their issuer and label with a random key,
+// stored next to the usage file (usage.key), so the usage file alone reveals
+// neither secrets nor account names.
+type Store struct {
+	path    string
+	entries map[string]*Entry
+
+	// Key the OTP key IDs are derived with, and whether it still has to be
+	// written to the key file
+	key      []byte
+	keyIsNew bool
+}
+
+// DefaultPath returns the path of the usage file,
+// $XDG_STATE_HOME/andotp-cli/usage.json or ~/.local/state/andotp-cli/usage.json
+func DefaultPath() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+
+	if stateHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "unable to find home directory")
+		}
+
+		stateHome = filepath.Join(homeDir, ".local", "state")
+	}
+
+	return filepath.Join(stateHome, "andotp-cli", "usage.json"), nil
+}
+
+// NewStore creates an empty Store persisted at path, with a new key. An empty
+// path keeps the usage in memory only.
+func NewStore(path string) *Store {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		memguard.SafePanic(err)
+	}
+
+	return &Store{path: path, entries: make(map[string]*Entry), key: key, keyIsNew: true}
+}
+
+// Open loads the Store at the default path. A missing file is an empty Store.
+func Open() (*Store, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return open(path)
+}
+
+// open loads the Store at path. Without a key file, the usage file is ignored
+// since its OTP key IDs can't be matched anymore.
+func open(path string) (*Store, error) {
+	store := NewStore(path)
+
+	key, err := ioutil.ReadFile(keyPath(path))
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "unable to read usage key file")
+	}
+
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid usage key file %s", keyPath(path))
+	}
+
+	store.key = key
+	store.keyIsNew = false
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "unable to read usage file")
+	}
+
+	if err := json.Unmarshal(content, &store.entries); err != nil {
+		return nil, errors.Wrapf(err, "invalid usage file %s", path)
+	}
+
+	if store.entries == nil {
+		store.entries = make(map[string]*Entry)
+	}
+
+	return store, nil
+}
+
+// keyPath returns the path of the key file of the usage file at path
+func keyPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "usage.key")
+}
+
+// keyID identifies an OTP key in the usage file
+func (s *Store) keyID(otpKey *otp.OTPKey) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(otpKey.Issuer + "\x00" + otpKey.Label))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Get returns the local usage of the OTP key
+func (s *Store) Get(otpKey *otp.OTPKey) Entry {
+	if entry, ok := s.entries[s.keyID(otpKey)]; ok {
+		return *entry
+	}
+
+	return Entry{}
+}
+
+// Frequency returns how often the OTP key was used: its local usage count plus
+// the usage count andOTP stored in the backup
+func (s *Store) Frequency(otpKey *otp.OTPKey) int {
+	return s.Get(otpKey).Count + otpKey.UsedFrequency
+}
+
+// Record counts a generated token of the OTP key and saves the Store
+func (s *Store) Record(otpKey *otp.OTPKey) error {
+	id := s.keyID(otpKey)
+
+	entry, ok := s.entries[id]
+	if !ok {
+		entry = &Entry{}
+		s.entries[id] = entry
+	}
+
+	entry.Count++
+	entry.LastUsed = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return s.Save()
+}
+
+// Save writes the Store to its file, replacing it atomically, and writes its
+// key to the key file if it's new
+func (s *Store) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize usage")
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "unable to create usage file directory")
+	}
+
+	if s.keyIsNew {
+		if err := ioutil.WriteFile(keyPath(s.path), s.key, 0600); err != nil {
+			return errors.Wrap(err, "unable to write usage key file")
+		}
+
+		s.keyIsNew = false
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, ".usage-*.json")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary usage file")
+	}
+
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "unable to write usage file")
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "unable to write usage file")
+	}
+
+	if err := os.Rename(tmpFile.Name(), s.path); err != nil {
+		return errors.Wrap(err, "unable to replace usage file")
+	}
+
+	return nil
+}
+
+// OpenOrEmpty is like Open, but logs the error and returns an in-memory Store
+// if the usage file can't be loaded, since usage is only used for ordering
+func OpenOrEmpty() *Store {
+	store, err := Open()
+	if err != nil {
+		log.Printf("Unable to load OTP key usage, it won't be recorded: %v", err)
+		return NewStore("")
+	}
+
+	return store
+}
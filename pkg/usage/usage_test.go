@@ -0,0 +1,201 @@
+package usage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/putrasattvika/andotp-cli/pkg/andotp/otp"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "andotp-cli", "usage.json")
+
+	github := &otp.OTPKey{Issuer: "GitHub", Label: "me@example.com", UsedFrequency: 5}
+	bank := &otp.OTPKey{Issuer: "Bank", Label: "token"}
+	unused := &otp.OTPKey{Issuer: "Unused", Label: "me@example.com", UsedFrequency: 1}
+
+	store, err := open(path)
+	if err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+
+	for _, otpKey := range []*otp.OTPKey{github, github, bank} {
+		if err := store.Record(otpKey); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	reopened, err := open(path)
+	if err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+
+	tests := []struct {
+		otpKey *otp.OTPKey
+		want   int
+	}{
+		{otpKey: github, want: 7},
+		{otpKey: bank, want: 1},
+		{otpKey: unused, want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := reopened.Frequency(tt.otpKey); got != tt.want {
+			t.Errorf("Frequency(%s) = %d, want %d", tt.otpKey.Issuer, got, tt.want)
+		}
+	}
+
+	if reopened.Get(github).LastUsed == 0 {
+		t.Errorf("Get(%s).LastUsed = 0, want the time of the last Record()", github.Issuer)
+	}
+
+	for _, file := range []string{path, keyPath(path)} {
+		stat, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mode := stat.Mode().Perm(); mode != 0600 {
+			t.Errorf("mode of %s = %o, want 600", filepath.Base(file), mode)
+		}
+	}
+
+	// Neither account names nor their plain hashes are in the usage file
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plainHash := sha256.Sum256([]byte(github.Issuer + "\x00" + github.Label))
+
+	for _, leaked := range []string{github.Issuer, github.Label, hex.EncodeToString(plainHash[:])} {
+		if bytes.Contains(content, []byte(leaked)) {
+			t.Errorf("usage file contains %q", leaked)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       []byte
+		usage     string
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "no files",
+			wantCount: 0,
+		},
+		{
+			name:      "usage file without key file",
+			usage:     `{"0000": {"count": 3, "last_used": 0}}`,
+			wantCount: 0,
+		},
+		{
+			name:    "invalid key file",
+			key:     []byte("short"),
+			wantErr: true,
+		},
+		{
+			name:    "corrupt usage file",
+			key:     bytes.Repeat([]byte{1}, keySize),
+			usage:   `{"count": `,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "usage.json")
+
+			if tt.key != nil {
+				if err := ioutil.WriteFile(keyPath(path), tt.key, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.usage != "" {
+				if err := ioutil.WriteFile(path, []byte(tt.usage), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			store, err := open(path)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("open() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("open() error = %v", err)
+			}
+
+			if len(store.entries) != tt.wantCount {
+				t.Errorf("open() loaded %d entries, want %d", len(store.entries), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestOpenOrEmptyCorruptFile(t *testing.T) {
+	stateHome := t.TempDir()
+
+	oldStateHome, hadStateHome := os.LookupEnv("XDG_STATE_HOME")
+	os.Setenv("XDG_STATE_HOME", stateHome)
+
+	defer func() {
+		if hadStateHome {
+			os.Setenv("XDG_STATE_HOME", oldStateHome)
+		} else {
+			os.Unsetenv("XDG_STATE_HOME")
+		}
+	}()
+
+	path := filepath.Join(stateHome, "andotp-cli", "usage.json")
+	corrupt := []byte(`{"count": `)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(keyPath(path), bytes.Repeat([]byte{1}, keySize), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, corrupt, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := OpenOrEmpty()
+
+	otpKey := &otp.OTPKey{Issuer: "GitHub", Label: "me", UsedFrequency: 2}
+
+	if err := store.Record(otpKey); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	// Usage is still counted in memory
+	if got := store.Frequency(otpKey); got != 3 {
+		t.Errorf("Frequency() = %d, want 3", got)
+	}
+
+	// The corrupt file is left alone
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(content, corrupt) {
+		t.Errorf("usage file = %q, want it unchanged", content)
+	}
+}